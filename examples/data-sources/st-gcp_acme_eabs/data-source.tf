@@ -0,0 +1,18 @@
+terraform {
+  required_providers {
+    st-gcp = {
+      source  = "myklst/st-gcp"
+      version = "~> 0.1"
+    }
+  }
+}
+
+provider "st-gcp" {}
+
+data "st-gcp_acme_eabs" "eabs" {
+  project = "my-project"
+}
+
+output "eab_count" {
+  value = length(data.st-gcp_acme_eabs.eabs.items)
+}
@@ -17,3 +17,17 @@ data "st-gcp_load_balancer_backend_services" "def" {
     app = "crond"
   }
 }
+
+data "st-gcp_load_balancer_backend_services" "internal" {
+  region = "asia-east1"
+
+  tags = {
+    env = "test"
+  }
+}
+
+data "st-gcp_load_balancer_backend_services" "all" {
+  all_regions = true
+  name        = "backend-service-name"
+  tag_format  = "auto"
+}
@@ -0,0 +1,16 @@
+terraform {
+  required_providers {
+    st-gcp = {
+      source  = "myklst/st-gcp"
+      version = "~> 0.1"
+    }
+  }
+}
+
+provider "st-gcp" {}
+
+# The EAB is created in every run and never stored in plan or state, pass
+# key_id and hmac_base64 to write-only arguments, e.g. of the ACME provider.
+ephemeral "st-gcp_acme_eab" "eab" {
+  environment = "staging"
+}
@@ -0,0 +1,3 @@
+# EAB can be imported by its name, the HMAC is provided via environment variable
+# or appended to the import ID after a comma.
+GOOGLE_PUBLICCA_EAB_HMAC="<b64MacKey>" terraform import st-gcp_acme_eab.eab projects/my-project/locations/global/externalAccountKeys/<key_id>
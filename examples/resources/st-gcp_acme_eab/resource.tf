@@ -10,6 +10,15 @@ terraform {
 provider "st-gcp" {}
 
 resource "st-gcp_acme_eab" "eab" {
+  rotation_days = 90
+
+  keepers = {
+    account = "acme@example.com"
+  }
+
+  timeouts {
+    create = "10m"
+  }
 }
 
 output "eab" {
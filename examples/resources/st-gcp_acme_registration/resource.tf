@@ -0,0 +1,19 @@
+terraform {
+  required_providers {
+    st-gcp = {
+      source  = "myklst/st-gcp"
+      version = "~> 0.1"
+    }
+  }
+}
+
+provider "st-gcp" {}
+
+resource "st-gcp_acme_registration" "reg" {
+  environment   = "staging"
+  email_address = "acme@example.com"
+}
+
+output "account_url" {
+  value = st-gcp_acme_registration.reg.account_url
+}
@@ -0,0 +1,4 @@
+# Tags can be imported by the relative resource name of a global or regional
+# backend service.
+terraform import st-gcp_load_balancer_backend_service_tags.web projects/my-project/regions/asia-east1/backendServices/web-backend
+terraform import st-gcp_load_balancer_backend_service_tags.web projects/my-project/global/backendServices/web-backend
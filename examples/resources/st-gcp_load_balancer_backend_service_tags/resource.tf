@@ -0,0 +1,20 @@
+terraform {
+  required_providers {
+    st-gcp = {
+      source  = "myklst/st-gcp"
+      version = "~> 0.1"
+    }
+  }
+}
+
+provider "st-gcp" {}
+
+resource "st-gcp_load_balancer_backend_service_tags" "web" {
+  name   = "web-backend"
+  region = "asia-east1"
+
+  tags = {
+    env  = "production"
+    team = "web"
+  }
+}
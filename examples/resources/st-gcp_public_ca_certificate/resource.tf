@@ -0,0 +1,25 @@
+terraform {
+  required_providers {
+    st-gcp = {
+      source  = "myklst/st-gcp"
+      version = "~> 0.1"
+    }
+  }
+}
+
+provider "st-gcp" {}
+
+resource "st-gcp_acme_registration" "reg" {
+  email_address = "acme@example.com"
+}
+
+resource "st-gcp_public_ca_certificate" "cert" {
+  directory_url   = st-gcp_acme_registration.reg.directory_url
+  account_key_pem = st-gcp_acme_registration.reg.account_key_pem
+  account_url     = st-gcp_acme_registration.reg.account_url
+
+  dns_names    = ["example.com", "*.example.com"]
+  managed_zone = "example-com"
+
+  min_days_remaining = 30
+}
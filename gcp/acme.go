@@ -0,0 +1,102 @@
+package gcp
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/acme"
+)
+
+// ACME directories of Google Public CA.
+// see: https://cloud.google.com/certificate-manager/docs/public-ca-tutorial
+const (
+	publicCADirectoryURLProduction = "https://dv.acme-v02.api.pki.goog/directory"
+	publicCADirectoryURLStaging    = "https://dv.acme-v02.test-api.pki.goog/directory"
+)
+
+// publicCADirectoryURL returns the ACME directory of Google Public CA in the
+// environment.
+func publicCADirectoryURL(environment string) string {
+	if environment == publicCAEnvironmentStaging {
+		return publicCADirectoryURLStaging
+	}
+	return publicCADirectoryURLProduction
+}
+
+// newAcmeClient returns an ACME client signing requests with the account key.
+// The kid is the account URL, which can be empty before registration. ACME
+// requests are authenticated by the account key, so the client shares the
+// user agent and logging of the provider but not the Google Cloud token, and
+// leaves retries to the ACME client itself.
+func (c *gcpClients) newAcmeClient(key crypto.Signer, directoryURL string, kid string) *acme.Client {
+	return &acme.Client{
+		Key:          key,
+		DirectoryURL: directoryURL,
+		KID:          acme.KeyID(kid),
+		UserAgent:    c.userAgent,
+		HTTPClient: &http.Client{
+			Transport: &loggingTransport{base: http.DefaultTransport},
+		},
+	}
+}
+
+// generateAccountKey generates an ECDSA P-256 account key, and returns it
+// together with its PEM encoding.
+func generateAccountKey() (crypto.Signer, string, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate account key: %v", err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal account key: %v", err)
+	}
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	return key, string(keyPem), nil
+}
+
+// parsePrivateKey parses a PEM encoded RSA or ECDSA private key in PKCS#1,
+// SEC 1 or PKCS#8 format.
+func parsePrivateKey(keyPem string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(keyPem))
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode PEM block of private key")
+	}
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch key := key.(type) {
+		case *ecdsa.PrivateKey:
+			return key, nil
+		case *rsa.PrivateKey:
+			return key, nil
+		}
+		return nil, fmt.Errorf("unsupported private key type %T, only RSA and ECDSA are supported", key)
+	}
+	return nil, fmt.Errorf("unsupported PEM block type %q of private key", block.Type)
+}
+
+// eabHmacKey decodes the base64url encoded HMAC of EAB into the MAC key.
+func eabHmacKey(hmacBase64 string) ([]byte, error) {
+	key, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(hmacBase64, "="))
+	if err != nil {
+		return nil, fmt.Errorf("failed to base64url-decode EAB HMAC: %v", err)
+	}
+	return key, nil
+}
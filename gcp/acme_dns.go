@@ -0,0 +1,219 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"golang.org/x/crypto/acme"
+	googleDNSClient "google.golang.org/api/dns/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+const (
+	dns01RecordTTL        = 60
+	dnsChangePollInterval = 2 * time.Second
+	dnsChangeStatusDone   = "done"
+	// dns01CleanupTimeout bounds the cleanup of challenge records, which runs
+	// after the request is cancelled or timed out.
+	dns01CleanupTimeout = 2 * time.Minute
+	// maxDNSChangeRetries is the number of times a change is built again
+	// after the records are changed by others between get and change, e.g.
+	// by another ACME client validating the same name.
+	maxDNSChangeRetries = 5
+)
+
+// dns01Solver fulfills DNS-01 challenges by writing TXT records in a Cloud DNS
+// managed zone.
+// see: https://www.rfc-editor.org/rfc/rfc8555#section-8.4
+type dns01Solver struct {
+	client      *googleDNSClient.Service
+	project     string
+	managedZone string
+
+	// records are the challenge values keyed by the record name, and
+	// presented are the names of records the values are added to.
+	records   map[string]*googleDNSClient.ResourceRecordSet
+	presented []string
+}
+
+func newDNS01Solver(ctx context.Context, clients *gcpClients,
+	project string, managedZone string) (*dns01Solver, error) {
+	client, err := googleDNSClient.NewService(ctx,
+		option.WithHTTPClient(clients.httpClient),
+		option.WithEndpoint(clients.dnsEndpoint))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize Cloud DNS client: %v", err)
+	}
+	return &dns01Solver{
+		client:      client,
+		project:     project,
+		managedZone: managedZone,
+		records:     map[string]*googleDNSClient.ResourceRecordSet{},
+	}, nil
+}
+
+// dns01RecordName returns the name of TXT record of the DNS-01 challenge for
+// the domain, the wildcard label is removed as required by RFC 8555.
+func dns01RecordName(domain string) string {
+	return "_acme-challenge." + strings.TrimPrefix(domain, "*.") + "."
+}
+
+// add records the challenge for the domain. A domain and its wildcard share
+// the same record, so values are accumulated until present is called.
+func (s *dns01Solver) add(domain string, value string) {
+	name := dns01RecordName(domain)
+	record, ok := s.records[name]
+	if !ok {
+		record = &googleDNSClient.ResourceRecordSet{
+			Name: name,
+			Type: "TXT",
+			Ttl:  dns01RecordTTL,
+		}
+		s.records[name] = record
+	}
+	record.Rrdatas = append(record.Rrdatas, `"`+value+`"`)
+}
+
+// present adds the challenge values to the TXT records and waits until the
+// change is applied. Values of others in the records, e.g. of another ACME
+// client validating the same name, are kept.
+func (s *dns01Solver) present(ctx context.Context) error {
+	names := slices.Sorted(maps.Keys(s.records))
+	change, err := s.change(ctx, names, func(rrdatas, values []string) []string {
+		for _, value := range values {
+			if !slices.Contains(rrdatas, value) {
+				rrdatas = append(rrdatas, value)
+			}
+		}
+		return rrdatas
+	})
+	if err != nil {
+		return err
+	}
+	// the values are written once the change is created, so they are cleaned
+	// up even if waiting for the change fails.
+	s.presented = names
+	if change == nil {
+		return nil
+	}
+	return s.wait(ctx, change)
+}
+
+// cleanup removes the challenge values from the TXT records as they are now,
+// keeping values added by others since present. It should be called with a
+// context which is not cancelled with the request, otherwise the values are
+// left behind on Ctrl-C or timeout.
+func (s *dns01Solver) cleanup(ctx context.Context) error {
+	if len(s.presented) == 0 {
+		return nil
+	}
+	change, err := s.change(ctx, s.presented, func(rrdatas, values []string) []string {
+		return slices.DeleteFunc(rrdatas, func(rrdata string) bool {
+			return slices.Contains(values, rrdata)
+		})
+	})
+	if err != nil || change == nil {
+		return err
+	}
+	return s.wait(ctx, change)
+}
+
+// change replaces the values of the TXT records of names by the values update
+// returns from the current values and the challenge values. Cloud DNS rejects
+// deletions which do not match the current records with 412, so the records
+// are read and the change is built again if others change them in between.
+// It returns nil if no record is changed.
+func (s *dns01Solver) change(ctx context.Context, names []string,
+	update func(rrdatas, values []string) []string) (*googleDNSClient.Change, error) {
+	for attempt := 0; ; attempt++ {
+		change := &googleDNSClient.Change{}
+		for _, name := range names {
+			existing, err := s.client.ResourceRecordSets.Get(s.project, s.managedZone, name, "TXT").Context(ctx).Do()
+			if err != nil && !isNotFound(err) {
+				return nil, fmt.Errorf("failed to get TXT record %s: %w", name, err)
+			}
+			record := &googleDNSClient.ResourceRecordSet{Name: name, Type: "TXT", Ttl: dns01RecordTTL}
+			if existing != nil {
+				record.Ttl = existing.Ttl
+				record.Rrdatas = slices.Clone(existing.Rrdatas)
+			}
+			record.Rrdatas = update(record.Rrdatas, s.records[name].Rrdatas)
+			if existing != nil {
+				if slices.Equal(record.Rrdatas, existing.Rrdatas) {
+					continue
+				}
+				change.Deletions = append(change.Deletions, existing)
+			}
+			if len(record.Rrdatas) > 0 {
+				change.Additions = append(change.Additions, record)
+			}
+		}
+		if len(change.Deletions) == 0 && len(change.Additions) == 0 {
+			return nil, nil
+		}
+
+		created, err := s.create(ctx, change)
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed &&
+			attempt < maxDNSChangeRetries {
+			tflog.Debug(ctx, "TXT records are changed by others, retrying", map[string]interface{}{
+				"managed_zone": s.managedZone,
+				"attempt":      attempt + 1,
+			})
+			continue
+		}
+		return created, err
+	}
+}
+
+func (s *dns01Solver) create(ctx context.Context, change *googleDNSClient.Change) (*googleDNSClient.Change, error) {
+	change, err := s.client.Changes.Create(s.project, s.managedZone, change).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to change records of managed zone %s: %w", s.managedZone, err)
+	}
+	return change, nil
+}
+
+func (s *dns01Solver) wait(ctx context.Context, change *googleDNSClient.Change) error {
+	for change.Status != dnsChangeStatusDone {
+		tflog.Debug(ctx, "Waiting for Cloud DNS change", map[string]interface{}{
+			"id":     change.Id,
+			"status": change.Status,
+		})
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(dnsChangePollInterval):
+		}
+		var err error
+		change, err = s.client.Changes.Get(s.project, s.managedZone, change.Id).Context(ctx).Do()
+		if err != nil {
+			return fmt.Errorf("failed to get change of managed zone %s: %w", s.managedZone, err)
+		}
+	}
+	return nil
+}
+
+// dns01Challenge returns the DNS-01 challenge of the authorization.
+func dns01Challenge(authz *acme.Authorization) (*acme.Challenge, error) {
+	for _, challenge := range authz.Challenges {
+		if challenge.Type == "dns-01" {
+			return challenge, nil
+		}
+	}
+	return nil, fmt.Errorf("no dns-01 challenge is offered for %s", authz.Identifier.Value)
+}
+
+// isNotFound reports whether err is a 404 error of Google Cloud APIs.
+func isNotFound(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
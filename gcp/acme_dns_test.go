@@ -0,0 +1,197 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	googleDNSClient "google.golang.org/api/dns/v1"
+)
+
+// fakeCloudDNS serves TXT records of a managed zone by the Cloud DNS API.
+type fakeCloudDNS struct {
+	*httptest.Server
+	mu      sync.Mutex
+	records map[string]*googleDNSClient.ResourceRecordSet
+	changes int
+	// failChanges fails every change with 400.
+	failChanges bool
+	// concurrentWrites are applied to the records before the changes in
+	// order, as if others changed them between get and change.
+	concurrentWrites []func(records map[string]*googleDNSClient.ResourceRecordSet)
+}
+
+func newFakeCloudDNS(t *testing.T, records ...*googleDNSClient.ResourceRecordSet) *fakeCloudDNS {
+	dns := &fakeCloudDNS{records: map[string]*googleDNSClient.ResourceRecordSet{}}
+	for _, record := range records {
+		dns.records[record.Name] = record
+	}
+	dns.Server = httptest.NewServer(http.HandlerFunc(dns.serveHTTP))
+	t.Cleanup(dns.Close)
+	return dns
+}
+
+func (f *fakeCloudDNS) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/rrsets/"):
+		// .../rrsets/{name}/TXT
+		parts := strings.Split(r.URL.Path, "/")
+		record, ok := f.records[parts[len(parts)-2]]
+		if !ok {
+			writeAPIError(w, http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(record)
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/changes"):
+		f.changes++
+		if f.failChanges {
+			writeAPIError(w, http.StatusBadRequest)
+			return
+		}
+		if len(f.concurrentWrites) > 0 {
+			f.concurrentWrites[0](f.records)
+			f.concurrentWrites = f.concurrentWrites[1:]
+		}
+		var change googleDNSClient.Change
+		if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+			writeAPIError(w, http.StatusBadRequest)
+			return
+		}
+		// deletions must match the existing records exactly.
+		for _, deletion := range change.Deletions {
+			existing, ok := f.records[deletion.Name]
+			if !ok || !slices.Equal(existing.Rrdatas, deletion.Rrdatas) {
+				writeAPIError(w, http.StatusPreconditionFailed)
+				return
+			}
+		}
+		for _, deletion := range change.Deletions {
+			delete(f.records, deletion.Name)
+		}
+		for _, addition := range change.Additions {
+			f.records[addition.Name] = addition
+		}
+		change.Id = "1"
+		change.Status = dnsChangeStatusDone
+		_ = json.NewEncoder(w).Encode(&change)
+	default:
+		writeAPIError(w, http.StatusNotFound)
+	}
+}
+
+func (f *fakeCloudDNS) rrdatas(name string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if record, ok := f.records[name]; ok {
+		return record.Rrdatas
+	}
+	return nil
+}
+
+func newTestDNS01Solver(t *testing.T, dns *fakeCloudDNS) *dns01Solver {
+	solver, err := newDNS01Solver(context.Background(), &gcpClients{
+		httpClient:  dns.Client(),
+		dnsEndpoint: dns.URL + "/",
+	}, "test-project", "test-zone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return solver
+}
+
+func TestDNS01SolverCleanupAfterCancel(t *testing.T) {
+	const name = "_acme-challenge.example.com."
+	dns := newFakeCloudDNS(t,
+		&googleDNSClient.ResourceRecordSet{Name: name, Type: "TXT", Ttl: 300, Rrdatas: []string{`"original"`}},
+	)
+	solver := newTestDNS01Solver(t, dns)
+	solver.add("example.com", "token")
+	solver.add("*.example.com", "wildcard-token")
+	solver.add("www.example.com", "www-token")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := solver.present(ctx); err != nil {
+		t.Fatalf("present: %v", err)
+	}
+	if got := dns.rrdatas(name); !slices.Equal(got, []string{`"original"`, `"token"`, `"wildcard-token"`}) {
+		t.Fatalf("present: %s = %q, want the challenge values added", name, got)
+	}
+
+	// the request is cancelled, e.g. by Ctrl-C, before cleanup.
+	cancel()
+	if err := solver.cleanup(ctx); err == nil {
+		t.Fatal("cleanup with the cancelled context succeeded")
+	}
+	if err := solver.cleanup(context.WithoutCancel(ctx)); err != nil {
+		t.Fatalf("cleanup: %v", err)
+	}
+	if got := dns.rrdatas(name); !slices.Equal(got, []string{`"original"`}) {
+		t.Errorf("cleanup: %s = %q, want the original value kept", name, got)
+	}
+	if got := dns.rrdatas("_acme-challenge.www.example.com."); got != nil {
+		t.Errorf("cleanup: challenge record of www.example.com is left behind: %q", got)
+	}
+}
+
+func TestDNS01SolverCleanupAfterFailedPresent(t *testing.T) {
+	dns := newFakeCloudDNS(t)
+	dns.failChanges = true
+	solver := newTestDNS01Solver(t, dns)
+	solver.add("example.com", "token")
+
+	if err := solver.present(context.Background()); err == nil {
+		t.Fatal("present succeeded")
+	}
+	if err := solver.cleanup(context.Background()); err != nil {
+		t.Fatalf("cleanup: %v", err)
+	}
+	if dns.changes != 1 {
+		t.Errorf("%d changes are created, want only the one of present", dns.changes)
+	}
+}
+
+func TestDNS01SolverConcurrentValidations(t *testing.T) {
+	const name = "_acme-challenge.example.com."
+	dns := newFakeCloudDNS(t)
+	first := newTestDNS01Solver(t, dns)
+	first.add("example.com", "first-token")
+	second := newTestDNS01Solver(t, dns)
+	second.add("example.com", "second-token")
+
+	if err := first.present(context.Background()); err != nil {
+		t.Fatalf("present first: %v", err)
+	}
+	// another ACME client adds its value between get and change.
+	dns.concurrentWrites = append(dns.concurrentWrites, func(records map[string]*googleDNSClient.ResourceRecordSet) {
+		records[name].Rrdatas = append(records[name].Rrdatas, `"external-token"`)
+	})
+	if err := second.present(context.Background()); err != nil {
+		t.Fatalf("present second: %v", err)
+	}
+	want := []string{`"first-token"`, `"external-token"`, `"second-token"`}
+	if got := dns.rrdatas(name); !slices.Equal(got, want) {
+		t.Fatalf("present: %s = %q, want %q", name, got, want)
+	}
+
+	if err := first.cleanup(context.Background()); err != nil {
+		t.Fatalf("cleanup first: %v", err)
+	}
+	want = []string{`"external-token"`, `"second-token"`}
+	if got := dns.rrdatas(name); !slices.Equal(got, want) {
+		t.Errorf("cleanup first: %s = %q, want %q", name, got, want)
+	}
+	if err := second.cleanup(context.Background()); err != nil {
+		t.Fatalf("cleanup second: %v", err)
+	}
+	want = []string{`"external-token"`}
+	if got := dns.rrdatas(name); !slices.Equal(got, want) {
+		t.Errorf("cleanup second: %s = %q, want %q", name, got, want)
+	}
+}
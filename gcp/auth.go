@@ -0,0 +1,141 @@
+package gcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+)
+
+const impersonateTokenLifetime = "3600s"
+
+// errUnauthenticated is wrapped by errors of raw HTTP requests which are
+// rejected by Google Cloud APIs with 401 status code.
+var errUnauthenticated = errors.New("request had invalid authentication credentials")
+
+// isUnauthenticated reports whether err is caused by credentials rejected by
+// Google Cloud APIs, e.g. an expired or revoked access token.
+func isUnauthenticated(err error) bool {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == http.StatusUnauthorized
+	}
+	return errors.Is(err, errUnauthenticated)
+}
+
+// apiErrorDetail returns the detail of an API error for diagnostics. Since a
+// raw access token can not be refreshed by the provider, a hint is appended
+// when it is rejected, which usually means it has expired in the middle of
+// an apply.
+func (c *gcpClients) apiErrorDetail(err error) string {
+	if c.accessToken && isUnauthenticated(err) {
+		return err.Error() + "\n\nThe access token configured in the provider " +
+			"has expired or been revoked. Access tokens can not be refreshed by " +
+			"the provider, please issue a new token with a lifetime long enough " +
+			"for the whole run and set it via access_token attribute or the " +
+			"GOOGLE_OAUTH_ACCESS_TOKEN environment variable."
+	}
+	return err.Error()
+}
+
+// impersonatedTokenSource exchanges the token of the base token source for an
+// access token of the target service account. Every delegate in the chain
+// must be granted roles/iam.serviceAccountTokenCreator on the next one.
+// see: https://cloud.google.com/iam/docs/reference/credentials/rest/v1/projects.serviceAccounts/generateAccessToken
+type impersonatedTokenSource struct {
+	client    *http.Client
+	endpoint  string
+	target    string
+	delegates []string
+	scopes    []string
+}
+
+type generateAccessTokenReq struct {
+	Delegates []string `json:"delegates,omitempty"`
+	Scope     []string `json:"scope"`
+	Lifetime  string   `json:"lifetime"`
+}
+
+type generateAccessTokenResp struct {
+	AccessToken string `json:"accessToken"`
+	ExpireTime  string `json:"expireTime"`
+}
+
+// newImpersonatedTokenSource returns a cached token source of the target
+// service account. The client must be authenticated by the base credentials,
+// and the endpoint is the base URL of IAM Credentials API.
+func newImpersonatedTokenSource(client *http.Client, endpoint string,
+	target string, delegates []string) oauth2.TokenSource {
+	return oauth2.ReuseTokenSource(nil, &impersonatedTokenSource{
+		client:    client,
+		endpoint:  endpoint,
+		target:    target,
+		delegates: delegates,
+		scopes:    []string{cloudPlatformScope},
+	})
+}
+
+// Token implements oauth2.TokenSource.
+func (s *impersonatedTokenSource) Token() (*oauth2.Token, error) {
+	reqBody := generateAccessTokenReq{
+		Scope:    s.scopes,
+		Lifetime: impersonateTokenLifetime,
+	}
+	for _, delegate := range s.delegates {
+		reqBody.Delegates = append(reqBody.Delegates, serviceAccountResourceName(delegate))
+	}
+	buf, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal generateAccessToken request: %v", err)
+	}
+
+	api := s.endpoint + "v1/" + serviceAccountResourceName(s.target) + ":generateAccessToken"
+	resp, err := s.client.Post(api, "application/json", bytes.NewReader(buf))
+	if err != nil {
+		return nil, fmt.Errorf("failed to impersonate service account %s: %v", s.target, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read generateAccessToken response body: %v", err)
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, fmt.Errorf("%w, failed to impersonate service account %s, url: %s, error: %s",
+			errUnauthenticated, s.target, api, string(body))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to impersonate service account %s, url: %s, error: %s",
+			s.target, api, string(body))
+	}
+
+	var token generateAccessTokenResp
+	if err = json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal generateAccessToken response: %v", err)
+	}
+	expiry, err := time.Parse(time.RFC3339, token.ExpireTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse expireTime of impersonated token: %v", err)
+	}
+	return &oauth2.Token{
+		AccessToken: token.AccessToken,
+		TokenType:   "Bearer",
+		Expiry:      expiry,
+	}, nil
+}
+
+// serviceAccountResourceName converts a service account email to the resource
+// name accepted by IAM Credentials API, leaving resource names untouched.
+func serviceAccountResourceName(account string) string {
+	if strings.HasPrefix(account, "projects/") {
+		return account
+	}
+	return "projects/-/serviceAccounts/" + account
+}
@@ -0,0 +1,90 @@
+package gcp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+)
+
+// clientOverride is the client_config of a resource or data source, resolved
+// per request. The zero value means the provider clients are used.
+type clientOverride struct {
+	project                   string
+	credentials               string // contents of the credentials file
+	accessToken               string
+	impersonateServiceAccount string
+	delegates                 []string
+	iamCredentialsEndpoint    string
+	computeEndpoint           string
+	dnsEndpoint               string
+	publicCAEndpoint          string
+}
+
+// isZero reports whether the override changes nothing of the provider.
+func (o clientOverride) isZero() bool {
+	return o.cacheFields() == clientOverride{}.cacheFields()
+}
+
+func (o clientOverride) cacheFields() [9]string {
+	return [9]string{
+		o.project,
+		o.credentials,
+		o.accessToken,
+		o.impersonateServiceAccount,
+		strings.Join(o.delegates, ","),
+		o.iamCredentialsEndpoint,
+		o.computeEndpoint,
+		o.dnsEndpoint,
+		o.publicCAEndpoint,
+	}
+}
+
+// cacheKey identifies the clients built for the override. Credentials are
+// hashed so that they are never kept in memory longer than the clients. An
+// access token is not part of the key but of the cached entry, so that the
+// clients of an expired token are replaced by the ones of its successor.
+func (o clientOverride) cacheKey() string {
+	fields := o.cacheFields()
+	if o.accessToken != "" {
+		fields[2] = "access_token"
+	}
+	h := sha256.New()
+	for _, field := range fields {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// accessTokenHash identifies the access token of the override, if any.
+func (o clientOverride) accessTokenHash() string {
+	if o.accessToken == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(o.accessToken))
+	return hex.EncodeToString(sum[:])
+}
+
+// clientCache keeps the clients built for client_config overrides, so that
+// they and their tokens are reused across requests. It is safe for concurrent
+// use since Terraform handles resources and data sources concurrently.
+//
+// Entries are never evicted, which is bounded by the distinct overrides in
+// the configuration, except that only the clients of the latest access token
+// are kept for each override, as access tokens are short-lived.
+type clientCache struct {
+	mu      sync.Mutex
+	clients map[string]*cachedClients
+}
+
+type cachedClients struct {
+	clients         *gcpClients
+	accessTokenHash string
+}
+
+func newClientCache() *clientCache {
+	return &clientCache{
+		clients: map[string]*cachedClients{},
+	}
+}
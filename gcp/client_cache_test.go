@@ -0,0 +1,55 @@
+package gcp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClientsForCredentialsFileRotatedInPlace(t *testing.T) {
+	c := newTestClients()
+	credentialsPath := filepath.Join(t.TempDir(), "credentials.json")
+	writeCredentials := func(project string) {
+		t.Helper()
+		content := `{"type":"authorized_user","client_id":"id","client_secret":"secret",` +
+			`"refresh_token":"token","quota_project_id":"` + project + `"}`
+		if err := os.WriteFile(credentialsPath, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config := newTestClientConfig(credentialsPath, "")
+
+	writeCredentials("first")
+	first := clientsForTest(t, c, config)
+	if first == c {
+		t.Fatal("clients of the provider are returned for overridden credentials")
+	}
+	if again := clientsForTest(t, c, config); again != first {
+		t.Error("clients of the same credentials are not reused")
+	}
+
+	writeCredentials("rotated")
+	if rotated := clientsForTest(t, c, config); rotated == first {
+		t.Error("clients of the rotated credentials file are the ones of the former credentials")
+	}
+}
+
+func TestClientsForAccessTokenReplacesFormerToken(t *testing.T) {
+	c := newTestClients()
+	first := clientsForTest(t, c, newTestClientConfig("", "first-token"))
+	if again := clientsForTest(t, c, newTestClientConfig("", "first-token")); again != first {
+		t.Error("clients of the same access token are not reused")
+	}
+
+	second := clientsForTest(t, c, newTestClientConfig("", "second-token"))
+	if second == first {
+		t.Fatal("clients of a new access token are the ones of the former token")
+	}
+	if len(c.cache.clients) != 1 {
+		t.Errorf("%d clients are cached, want only the ones of the latest access token", len(c.cache.clients))
+	}
+	token, err := second.tokenSource.Token()
+	if err != nil || token.AccessToken != "second-token" {
+		t.Errorf("token = %v, %v, want second-token", token, err)
+	}
+}
@@ -0,0 +1,382 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	dataSourceSchema "github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	ephemeralSchema "github.com/hashicorp/terraform-plugin-framework/ephemeral/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	resourceSchema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/mitchellh/go-homedir"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	googleComputeClient "google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+)
+
+// clientConfig is the client_config block embedded in every resource and data
+// source to override the clients of the provider. Precedence is the same for
+// every attribute:
+//
+//   - An attribute set in the block overrides the same attribute of the
+//     provider, and an unset one inherits the provider configuration.
+//   - access_token takes precedence over credentials, as in the provider.
+//   - Impersonation of the provider is only inherited when neither
+//     credentials nor access_token is overridden, since it is bound to the
+//     credentials of the provider.
+//   - impersonate_service_account replaces the impersonation of the provider
+//     instead of chaining onto it, so the service account is impersonated by
+//     the credentials of the provider before their impersonation.
+//   - project defaults to the project of the overriding credentials if it can
+//     be detected, then the project of the provider.
+//   - A project attribute of the resource itself, if any, takes precedence
+//     over client_config.
+type clientConfig struct {
+	Project                            types.String `tfsdk:"project"`
+	Credentials                        types.String `tfsdk:"credentials"`
+	AccessToken                        types.String `tfsdk:"access_token"`
+	ImpersonateServiceAccount          types.String `tfsdk:"impersonate_service_account"`
+	ImpersonateServiceAccountDelegates types.List   `tfsdk:"impersonate_service_account_delegates"`
+	IamCredentialsCustomEndpoint       types.String `tfsdk:"iam_credentials_custom_endpoint"`
+	ComputeCustomEndpoint              types.String `tfsdk:"compute_custom_endpoint"`
+	DNSCustomEndpoint                  types.String `tfsdk:"dns_custom_endpoint"`
+	PublicCACustomEndpoint             types.String `tfsdk:"publicca_custom_endpoint"`
+}
+
+const (
+	clientConfigDescription = "Config to override the clients of the provider. " +
+		"Attributes set in this block override the same attributes of the " +
+		"provider, and unset ones inherit the provider configuration. " +
+		"access_token takes precedence over credentials, and the impersonation " +
+		"of the provider is only inherited when neither of them is overridden."
+	clientConfigProjectDescription = "Project Name for Google Cloud API. Default " +
+		"to the project of credentials in this block if it can be detected, then " +
+		"the project configured in the provider."
+	clientConfigCredentialsDescription = "Either the path to or the contents of " +
+		"a credentials file in JSON format, which can be a service account key, " +
+		"an authorized user credentials or an external account credential " +
+		"configuration. Default to use credentials configured in the provider."
+	clientConfigAccessTokenDescription = "A temporary OAuth 2.0 access token, " +
+		"used instead of credentials. Default to use credentials configured in " +
+		"the provider."
+	clientConfigImpersonateDescription = "The service account to impersonate. " +
+		"It replaces the impersonation configured in the provider, so it is " +
+		"impersonated by the credentials of the provider, not the service " +
+		"account impersonated by the provider. Default to use impersonation " +
+		"configured in the provider."
+	clientConfigDelegatesDescription = "The delegation chain for impersonating " +
+		"the service account."
+	clientConfigIamCredentialsEndpointDescription = "Custom endpoint of IAM " +
+		"Credentials API. Default to the endpoint configured in the provider."
+	clientConfigComputeEndpointDescription = "Custom endpoint of Compute Engine " +
+		"API. Default to the endpoint configured in the provider."
+	clientConfigDNSEndpointDescription = "Custom endpoint of Cloud DNS API. " +
+		"Default to the endpoint configured in the provider."
+	clientConfigPublicCAEndpointDescription = "Custom endpoint of Public " +
+		"Certificate Authority API for both production and staging environments. " +
+		"Default to the endpoints configured in the provider."
+)
+
+// dataSourceClientConfigBlock returns the client_config block of data sources.
+func dataSourceClientConfigBlock() dataSourceSchema.SingleNestedBlock {
+	return dataSourceSchema.SingleNestedBlock{
+		Description: clientConfigDescription + " This block will not be recorded " +
+			"in state file.",
+		Attributes: map[string]dataSourceSchema.Attribute{
+			"project": dataSourceSchema.StringAttribute{
+				Description: clientConfigProjectDescription,
+				Optional:    true,
+			},
+			"credentials": dataSourceSchema.StringAttribute{
+				Description: clientConfigCredentialsDescription,
+				Optional:    true,
+				Sensitive:   true,
+			},
+			"access_token": dataSourceSchema.StringAttribute{
+				Description: clientConfigAccessTokenDescription,
+				Optional:    true,
+				Sensitive:   true,
+			},
+			"impersonate_service_account": dataSourceSchema.StringAttribute{
+				Description: clientConfigImpersonateDescription,
+				Optional:    true,
+			},
+			"impersonate_service_account_delegates": dataSourceSchema.ListAttribute{
+				Description: clientConfigDelegatesDescription,
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"iam_credentials_custom_endpoint": dataSourceSchema.StringAttribute{
+				Description: clientConfigIamCredentialsEndpointDescription,
+				Optional:    true,
+			},
+			"compute_custom_endpoint": dataSourceSchema.StringAttribute{
+				Description: clientConfigComputeEndpointDescription,
+				Optional:    true,
+			},
+			"dns_custom_endpoint": dataSourceSchema.StringAttribute{
+				Description: clientConfigDNSEndpointDescription,
+				Optional:    true,
+			},
+			"publicca_custom_endpoint": dataSourceSchema.StringAttribute{
+				Description: clientConfigPublicCAEndpointDescription,
+				Optional:    true,
+			},
+		},
+	}
+}
+
+// resourceClientConfigBlock returns the client_config block of resources.
+// Changing it never forces a new resource to be created.
+func resourceClientConfigBlock() resourceSchema.SingleNestedBlock {
+	return resourceSchema.SingleNestedBlock{
+		Description: clientConfigDescription + " Changing this block never " +
+			"forces a new resource to be created. This block is recorded in " +
+			"state file with credentials and access_token marked as sensitive.",
+		Attributes: map[string]resourceSchema.Attribute{
+			"project": resourceSchema.StringAttribute{
+				Description: clientConfigProjectDescription,
+				Optional:    true,
+			},
+			"credentials": resourceSchema.StringAttribute{
+				Description: clientConfigCredentialsDescription,
+				Optional:    true,
+				Sensitive:   true,
+			},
+			"access_token": resourceSchema.StringAttribute{
+				Description: clientConfigAccessTokenDescription,
+				Optional:    true,
+				Sensitive:   true,
+			},
+			"impersonate_service_account": resourceSchema.StringAttribute{
+				Description: clientConfigImpersonateDescription,
+				Optional:    true,
+			},
+			"impersonate_service_account_delegates": resourceSchema.ListAttribute{
+				Description: clientConfigDelegatesDescription,
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"iam_credentials_custom_endpoint": resourceSchema.StringAttribute{
+				Description: clientConfigIamCredentialsEndpointDescription,
+				Optional:    true,
+			},
+			"compute_custom_endpoint": resourceSchema.StringAttribute{
+				Description: clientConfigComputeEndpointDescription,
+				Optional:    true,
+			},
+			"dns_custom_endpoint": resourceSchema.StringAttribute{
+				Description: clientConfigDNSEndpointDescription,
+				Optional:    true,
+			},
+			"publicca_custom_endpoint": resourceSchema.StringAttribute{
+				Description: clientConfigPublicCAEndpointDescription,
+				Optional:    true,
+			},
+		},
+	}
+}
+
+// ephemeralResourceClientConfigBlock returns the client_config block of
+// ephemeral resources.
+func ephemeralResourceClientConfigBlock() ephemeralSchema.SingleNestedBlock {
+	return ephemeralSchema.SingleNestedBlock{
+		Description: clientConfigDescription,
+		Attributes: map[string]ephemeralSchema.Attribute{
+			"project": ephemeralSchema.StringAttribute{
+				Description: clientConfigProjectDescription,
+				Optional:    true,
+			},
+			"credentials": ephemeralSchema.StringAttribute{
+				Description: clientConfigCredentialsDescription,
+				Optional:    true,
+				Sensitive:   true,
+			},
+			"access_token": ephemeralSchema.StringAttribute{
+				Description: clientConfigAccessTokenDescription,
+				Optional:    true,
+				Sensitive:   true,
+			},
+			"impersonate_service_account": ephemeralSchema.StringAttribute{
+				Description: clientConfigImpersonateDescription,
+				Optional:    true,
+			},
+			"impersonate_service_account_delegates": ephemeralSchema.ListAttribute{
+				Description: clientConfigDelegatesDescription,
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"iam_credentials_custom_endpoint": ephemeralSchema.StringAttribute{
+				Description: clientConfigIamCredentialsEndpointDescription,
+				Optional:    true,
+			},
+			"compute_custom_endpoint": ephemeralSchema.StringAttribute{
+				Description: clientConfigComputeEndpointDescription,
+				Optional:    true,
+			},
+			"dns_custom_endpoint": ephemeralSchema.StringAttribute{
+				Description: clientConfigDNSEndpointDescription,
+				Optional:    true,
+			},
+			"publicca_custom_endpoint": ephemeralSchema.StringAttribute{
+				Description: clientConfigPublicCAEndpointDescription,
+				Optional:    true,
+			},
+		},
+	}
+}
+
+// clientsFor returns the clients of the provider overridden by the
+// client_config block, which can be nil. Overridden clients are cached, so
+// they and their tokens are reused across requests, and the provider clients
+// are never modified.
+func (c *gcpClients) clientsFor(ctx context.Context, config *clientConfig) (*gcpClients, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	if config == nil {
+		return c, diags
+	}
+
+	o := clientOverride{
+		project:                   config.Project.ValueString(),
+		credentials:               config.Credentials.ValueString(),
+		accessToken:               config.AccessToken.ValueString(),
+		impersonateServiceAccount: config.ImpersonateServiceAccount.ValueString(),
+		iamCredentialsEndpoint:    config.IamCredentialsCustomEndpoint.ValueString(),
+		computeEndpoint:           config.ComputeCustomEndpoint.ValueString(),
+		dnsEndpoint:               config.DNSCustomEndpoint.ValueString(),
+		publicCAEndpoint:          config.PublicCACustomEndpoint.ValueString(),
+	}
+	diags.Append(config.ImpersonateServiceAccountDelegates.ElementsAs(ctx, &o.delegates, false)...)
+	if diags.HasError() {
+		return nil, diags
+	}
+	if o.isZero() {
+		return c, diags
+	}
+
+	// credentials are identified by the contents instead of the path, so that
+	// a credentials file rotated in place is loaded again. They are ignored if
+	// access_token is set.
+	if o.accessToken != "" {
+		o.credentials = ""
+	} else if o.credentials != "" {
+		credentialsContent, err := loadCredentials(o.credentials)
+		if err != nil {
+			diags.AddAttributeError(
+				path.Root("client_config").AtName("credentials"),
+				"[INTERNAL ERROR] Failed to load credentials",
+				err.Error(),
+			)
+			return nil, diags
+		}
+		o.credentials = string(credentialsContent)
+	}
+
+	key := o.cacheKey()
+	c.cache.mu.Lock()
+	defer c.cache.mu.Unlock()
+	if cached, ok := c.cache.clients[key]; ok && cached.accessTokenHash == o.accessTokenHash() {
+		return cached.clients, diags
+	}
+
+	// Copy the provider clients, and replace what is overridden.
+	clients := *c
+	if o.iamCredentialsEndpoint != "" {
+		clients.iamCredentialsEndpoint = serviceEndpoint(types.StringValue(o.iamCredentialsEndpoint), "")
+	}
+	if o.computeEndpoint != "" {
+		clients.computeEndpoint = serviceEndpoint(types.StringValue(o.computeEndpoint), "")
+	}
+	if o.dnsEndpoint != "" {
+		clients.dnsEndpoint = serviceEndpoint(types.StringValue(o.dnsEndpoint), "")
+	}
+	if o.publicCAEndpoint != "" {
+		clients.publicCAEndpoint = serviceEndpoint(types.StringValue(o.publicCAEndpoint), "")
+		clients.publicCAStagingEndpoint = clients.publicCAEndpoint
+	}
+
+	// Token sources outlive the request as they are cached, so they must not
+	// be bound to the context of the request.
+	var credentialsProject string
+	switch {
+	case o.accessToken != "":
+		clients.baseTokenSource = oauth2.StaticTokenSource(&oauth2.Token{
+			AccessToken: o.accessToken,
+			TokenType:   "Bearer",
+		})
+		clients.tokenSource = clients.baseTokenSource
+		clients.accessToken = true
+	case o.credentials != "":
+		googleCredentials, err := google.CredentialsFromJSON(context.Background(), []byte(o.credentials), cloudPlatformScope)
+		if err != nil {
+			diags.AddAttributeError(
+				path.Root("client_config").AtName("credentials"),
+				"Invalid Google Cloud API credentials",
+				"Please make sure the credentials is a valid service account key, "+
+					"authorized user or external account credential configuration.\n"+
+					"Additional error message: "+err.Error(),
+			)
+			return nil, diags
+		}
+		clients.baseTokenSource = googleCredentials.TokenSource
+		clients.tokenSource = clients.baseTokenSource
+		clients.accessToken = false
+		credentialsProject = googleCredentials.ProjectID
+	}
+	// the impersonation of the provider is replaced instead of chained, so
+	// the service account is impersonated by the credentials themselves.
+	if o.impersonateServiceAccount != "" {
+		clients.tokenSource = newImpersonatedTokenSource(
+			newHTTPClient(clients.baseTokenSource, clients.userAgent, clients.retry),
+			clients.iamCredentialsEndpoint, o.impersonateServiceAccount, o.delegates)
+	}
+
+	switch {
+	case o.project != "":
+		clients.project = o.project
+	case credentialsProject != "":
+		clients.project = credentialsProject
+	}
+
+	clients.httpClient = newHTTPClient(clients.baseTokenSource, clients.userAgent, clients.retry)
+	computeClient, err := googleComputeClient.NewService(ctx,
+		option.WithHTTPClient(clients.httpClient), option.WithEndpoint(clients.computeEndpoint))
+	if err != nil {
+		diags.AddError(
+			"[API ERROR] Failed to Reinitialize Google Cloud client",
+			"Please make sure the credentials is valid.\n"+
+				"Additional error message: "+err.Error(),
+		)
+		return nil, diags
+	}
+	clients.computeClient = computeClient
+
+	c.cache.clients[key] = &cachedClients{
+		clients:         &clients,
+		accessTokenHash: o.accessTokenHash(),
+	}
+	return &clients, diags
+}
+
+// loadCredentials returns the contents of credentials, which is either the
+// path to or the contents of a credentials file in JSON format.
+// reference:
+// - https://github.com/hashicorp/terraform-provider-google/blob/80f6dd2fcc1c209ed2b066d9b758db2e34145368/google/path_or_contents.go
+func loadCredentials(credentials string) ([]byte, error) {
+	credentialsPath, err := homedir.Expand(credentials)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand homedir of credentials file: %v", err)
+	}
+	if _, err := os.Stat(credentialsPath); err != nil {
+		return []byte(credentials), nil
+	}
+	content, err := os.ReadFile(credentialsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials file: %v", err)
+	}
+	return content, nil
+}
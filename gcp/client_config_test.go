@@ -0,0 +1,109 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"golang.org/x/oauth2"
+)
+
+func newTestClients() *gcpClients {
+	return &gcpClients{
+		project:         "test-project",
+		computeEndpoint: "http://127.0.0.1/compute/v1/",
+		retry:           retryPolicy{maxRetries: defaultMaxRetries, timeout: defaultRequestTimeout},
+		cache:           newClientCache(),
+	}
+}
+
+func newTestClientConfig(credentials, accessToken string) *clientConfig {
+	config := &clientConfig{
+		Project:                            types.StringNull(),
+		Credentials:                        types.StringNull(),
+		AccessToken:                        types.StringNull(),
+		ImpersonateServiceAccount:          types.StringNull(),
+		ImpersonateServiceAccountDelegates: types.ListNull(types.StringType),
+		IamCredentialsCustomEndpoint:       types.StringNull(),
+		ComputeCustomEndpoint:              types.StringNull(),
+		DNSCustomEndpoint:                  types.StringNull(),
+		PublicCACustomEndpoint:             types.StringNull(),
+	}
+	if credentials != "" {
+		config.Credentials = types.StringValue(credentials)
+	}
+	if accessToken != "" {
+		config.AccessToken = types.StringValue(accessToken)
+	}
+	return config
+}
+
+func clientsForTest(t *testing.T, c *gcpClients, config *clientConfig) *gcpClients {
+	t.Helper()
+	clients, diags := c.clientsFor(context.Background(), config)
+	if diags.HasError() {
+		t.Fatalf("clientsFor: %v", diags)
+	}
+	return clients
+}
+
+// fakeIAMCredentials serves generateAccessToken of the IAM Credentials API,
+// which returns "token-of-<service account>", and records who impersonates
+// whom.
+type fakeIAMCredentials struct {
+	*httptest.Server
+	mu sync.Mutex
+	// impersonations are "<access token> -> <service account>" in order.
+	impersonations []string
+}
+
+func newFakeIAMCredentials(t *testing.T) *fakeIAMCredentials {
+	iam := &fakeIAMCredentials{}
+	iam.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// .../v1/projects/-/serviceAccounts/{email}:generateAccessToken
+		target := strings.TrimSuffix(r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:], ":generateAccessToken")
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		iam.mu.Lock()
+		iam.impersonations = append(iam.impersonations, token+" -> "+target)
+		iam.mu.Unlock()
+		_ = json.NewEncoder(w).Encode(generateAccessTokenResp{
+			AccessToken: "token-of-" + target,
+			ExpireTime:  time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+		})
+	}))
+	t.Cleanup(iam.Close)
+	return iam
+}
+
+func TestClientsForImpersonationReplacesProviderImpersonation(t *testing.T) {
+	iam := newFakeIAMCredentials(t)
+	c := newTestClients()
+	c.iamCredentialsEndpoint = iam.URL + "/"
+	c.baseTokenSource = oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "base-token"})
+	c.tokenSource = newImpersonatedTokenSource(newHTTPClient(c.baseTokenSource, "", c.retry),
+		c.iamCredentialsEndpoint, "sa-a@test-project.iam.gserviceaccount.com", nil)
+
+	config := newTestClientConfig("", "")
+	config.ImpersonateServiceAccount = types.StringValue("sa-b@test-project.iam.gserviceaccount.com")
+	clients := clientsForTest(t, c, config)
+	token, err := clients.tokenSource.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "token-of-sa-b@test-project.iam.gserviceaccount.com" {
+		t.Errorf("token = %s, want the one of sa-b", token.AccessToken)
+	}
+	iam.mu.Lock()
+	defer iam.mu.Unlock()
+	want := []string{"base-token -> sa-b@test-project.iam.gserviceaccount.com"}
+	if !slices.Equal(iam.impersonations, want) {
+		t.Errorf("impersonations = %q, want %q", iam.impersonations, want)
+	}
+}
@@ -0,0 +1,266 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+var (
+	_ datasource.DataSource              = &AcmeEabsDataSource{}
+	_ datasource.DataSourceWithConfigure = &AcmeEabsDataSource{}
+)
+
+const (
+	eabsSourceAPI    = "api"
+	eabsSourceLedger = "ledger"
+)
+
+// NewAcmeEabsDataSource
+func NewAcmeEabsDataSource() datasource.DataSource {
+	return &AcmeEabsDataSource{}
+}
+
+// AcmeEabsDataSource
+type AcmeEabsDataSource struct {
+	client *gcpClients
+}
+
+// AcmeEabsDataSourceModel
+type AcmeEabsDataSourceModel struct {
+	ClientConfig *clientConfig        `tfsdk:"client_config"`
+	Project      types.String         `tfsdk:"project"`
+	Location     types.String         `tfsdk:"location"`
+	Environment  types.String         `tfsdk:"environment"`
+	Source       types.String         `tfsdk:"source"`
+	Items        []*acmeEabsItemModel `tfsdk:"items"`
+}
+
+type acmeEabsItemModel struct {
+	KeyID    types.String `tfsdk:"key_id"`
+	Name     types.String `tfsdk:"name"`
+	CreateAt types.Int64  `tfsdk:"create_at"`
+}
+
+type externalAccountKeysListResp struct {
+	ExternalAccountKeys []struct {
+		Name       string `json:"name"`
+		KeyID      string `json:"keyId"`
+		CreateTime string `json:"createTime"`
+	} `json:"externalAccountKeys"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// Metadata returns the data source EABs type name.
+func (d *AcmeEabsDataSource) Metadata(_ context.Context,
+	req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_acme_eabs"
+}
+
+// Schema defines the schema for the EABs data source.
+func (d *AcmeEabsDataSource) Schema(_ context.Context,
+	_ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "This data source provides the EAB credentials of Google " +
+			"Public CA in a project. Since Public CA API does not list EABs, they " +
+			"are read from the local ledger of the provider, which only contains " +
+			"EABs created by the provider.",
+		Attributes: map[string]schema.Attribute{
+			"project": schema.StringAttribute{
+				Description: "The project of EABs. Default to the project of " +
+					"client_config, then the project configured in the provider.",
+				Optional: true,
+			},
+			"location": schema.StringAttribute{
+				Description: "The location of EABs. Default to global.",
+				Optional:    true,
+			},
+			"environment": schema.StringAttribute{
+				Description: "Environment of Google Public CA, either production or " +
+					"staging. Default to production.",
+				Optional: true,
+				Validators: []validator.String{
+					stringvalidator.OneOf(publicCAEnvironmentProduction, publicCAEnvironmentStaging),
+				},
+			},
+			"source": schema.StringAttribute{
+				Description: "Where the EABs are read from, either api or ledger.",
+				Computed:    true,
+			},
+			"items": schema.ListNestedAttribute{
+				Description: "List of EABs.",
+				Computed:    true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"key_id": schema.StringAttribute{
+							Description: "EAB key ID.",
+							Computed:    true,
+						},
+						"name": schema.StringAttribute{
+							Description: "EAB name.",
+							Computed:    true,
+						},
+						"create_at": schema.Int64Attribute{
+							Description: "EAB create timestamp.",
+							Computed:    true,
+						},
+					},
+				},
+			},
+		},
+		Blocks: map[string]schema.Block{
+			"client_config": dataSourceClientConfigBlock(),
+		},
+	}
+}
+
+// Configure adds the provider configured client to the data source.
+func (d *AcmeEabsDataSource) Configure(_ context.Context,
+	req datasource.ConfigureRequest, _ *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	d.client = req.ProviderData.(*gcpClients)
+}
+
+// Read EABs data source information
+func (d *AcmeEabsDataSource) Read(ctx context.Context,
+	req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var plan *AcmeEabsDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	clients, diags := d.client.clientsFor(ctx, plan.ClientConfig)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	project := plan.Project.ValueString()
+	if project == "" {
+		project = clients.project
+	}
+	location := plan.Location.ValueString()
+	if location == "" {
+		location = "global"
+	}
+	environment := plan.Environment.ValueString()
+	if environment == "" {
+		environment = publicCAEnvironmentProduction
+	}
+
+	state := &AcmeEabsDataSourceModel{
+		Project:     types.StringValue(project),
+		Location:    types.StringValue(location),
+		Environment: types.StringValue(environment),
+		Items:       []*acmeEabsItemModel{},
+	}
+
+	items, listed, err := listEabs(ctx, clients, project, location, environment)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"[API ERROR] Failed to list EAB credentials.",
+			clients.apiErrorDetail(err),
+		)
+		return
+	}
+	if listed {
+		state.Source = types.StringValue(eabsSourceAPI)
+		state.Items = items
+	} else {
+		entries, err := readEabLedger(d.client.eabLedgerPath, project, location)
+		if err != nil {
+			resp.Diagnostics.AddError("[INTERNAL ERROR] Failed to read EAB ledger.", err.Error())
+			return
+		}
+		state.Source = types.StringValue(eabsSourceLedger)
+		for _, entry := range entries {
+			if entry.Environment != environment {
+				continue
+			}
+			state.Items = append(state.Items, &acmeEabsItemModel{
+				KeyID:    types.StringValue(entry.KeyID),
+				Name:     types.StringValue(entry.Name),
+				CreateAt: types.Int64Value(entry.CreateAt),
+			})
+		}
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
+
+// listEabs lists EABs through Public CA API. It reports false without error
+// if the API does not support listing, which is the case for v1 and v1beta1.
+func listEabs(ctx context.Context, clients *gcpClients, project string,
+	location string, environment string) ([]*acmeEabsItemModel, bool, error) {
+	endpoint := clients.publicCAEndpoint
+	if environment == publicCAEnvironmentStaging {
+		endpoint = clients.publicCAStagingEndpoint
+	}
+	client := clients.httpClient
+
+	items := []*acmeEabsItemModel{}
+	pageToken := ""
+	for {
+		api := fmt.Sprintf("%sv1/projects/%s/locations/%s/externalAccountKeys?pageToken=%s",
+			endpoint, project, location, url.QueryEscape(pageToken))
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, api, nil)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to build request: %v", err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, false, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read response body: %v", err)
+		}
+		switch resp.StatusCode {
+		case http.StatusOK:
+		case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+			tflog.Debug(ctx, "Public CA API does not support listing EABs, fallback to ledger", map[string]interface{}{
+				"status": resp.StatusCode,
+			})
+			return nil, false, nil
+		case http.StatusUnauthorized:
+			return nil, false, fmt.Errorf("%w, url: %s, error: %s", errUnauthenticated, api, string(body))
+		default:
+			return nil, false, fmt.Errorf("url: %s, error: %s", api, string(body))
+		}
+
+		var list externalAccountKeysListResp
+		if err := json.Unmarshal(body, &list); err != nil {
+			return nil, false, fmt.Errorf("failed to unmarshal EAB list response: %v", err)
+		}
+		for _, key := range list.ExternalAccountKeys {
+			item := &acmeEabsItemModel{
+				KeyID:    types.StringValue(key.KeyID),
+				Name:     types.StringValue(key.Name),
+				CreateAt: types.Int64Null(),
+			}
+			if createTime, err := time.Parse(time.RFC3339, key.CreateTime); err == nil {
+				item.CreateAt = types.Int64Value(createTime.Unix())
+			}
+			items = append(items, item)
+		}
+		if list.NextPageToken == "" {
+			return items, true, nil
+		}
+		pageToken = list.NextPageToken
+	}
+}
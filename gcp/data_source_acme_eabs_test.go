@@ -0,0 +1,37 @@
+package gcp
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+func TestAcmeEabsFallbackToLedgerWithoutRetries(t *testing.T) {
+	for _, status := range []int{http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			ca := newFakePublicCA(t)
+			ca.listStatus = status
+			p := newTestProvider(t, map[string]tftypes.Value{
+				"publicca_custom_endpoint": tftypes.NewValue(tftypes.String, ca.URL),
+			})
+			eabType := p.resourceType("st-gcp_acme_eab")
+			eab, _ := p.apply("st-gcp_acme_eab", tftypes.NewValue(eabType, nil), p.object(eabType, nil))
+
+			state := p.readDataSource("st-gcp_acme_eabs", p.object(p.dataSourceType("st-gcp_acme_eabs"), nil))
+			if got := ca.lists.Load(); got != 1 {
+				t.Errorf("%d list requests are sent, want 1", got)
+			}
+			if got := attrString(t, state, "source"); got != eabsSourceLedger {
+				t.Errorf("source = %s, want %s", got, eabsSourceLedger)
+			}
+			var items []tftypes.Value
+			if err := attrValue(t, state, "items").As(&items); err != nil {
+				t.Fatal(err)
+			}
+			if len(items) != 1 || attrString(t, items[0], "key_id") != attrString(t, eab, "key_id") {
+				t.Errorf("items = %v, want the EAB in the ledger", items)
+			}
+		})
+	}
+}
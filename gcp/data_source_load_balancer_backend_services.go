@@ -3,21 +3,28 @@ package gcp
 import (
 	"context"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
 	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 
 	googleComputeClient "google.golang.org/api/compute/v1"
-	"google.golang.org/api/option"
+
+	"github.com/myklst/terraform-provider-st-gcp/internal/tagcodec"
 )
 
 var (
-	_ datasource.DataSource              = &LbBackendServicesDataSource{}
-	_ datasource.DataSourceWithConfigure = &LbBackendServicesDataSource{}
+	_ datasource.DataSource                   = &LbBackendServicesDataSource{}
+	_ datasource.DataSourceWithConfigure      = &LbBackendServicesDataSource{}
+	_ datasource.DataSourceWithValidateConfig = &LbBackendServicesDataSource{}
 )
 
 // NewLbBackendServicesDataSource
@@ -27,34 +34,74 @@ func NewLbBackendServicesDataSource() datasource.DataSource {
 
 // LbBackendServicesDataSource
 type LbBackendServicesDataSource struct {
-	project string
-	client  *googleComputeClient.Service
+	clients *gcpClients
 }
 
 // LbBackendServicesDataSourceModel
 type LbBackendServicesDataSourceModel struct {
 	ClientConfig *clientConfig                 `tfsdk:"client_config"`
 	Name         types.String                  `tfsdk:"name"`
+	Region       types.String                  `tfsdk:"region"`
+	AllRegions   types.Bool                    `tfsdk:"all_regions"`
+	TagFormat    types.String                  `tfsdk:"tag_format"`
 	Tags         types.Map                     `tfsdk:"tags"`
 	Items        []*lbBackendServicesItemModel `tfsdk:"items"`
 }
 
 type lbBackendServicesItemModel struct {
-	ID   types.Int64 `tfsdk:"id"`
-	Tags types.Map   `tfsdk:"tags"`
+	ID                  types.Int64                     `tfsdk:"id"`
+	Name                types.String                    `tfsdk:"name"`
+	SelfLink            types.String                    `tfsdk:"self_link"`
+	Scope               types.String                    `tfsdk:"scope"`
+	Protocol            types.String                    `tfsdk:"protocol"`
+	PortName            types.String                    `tfsdk:"port_name"`
+	LoadBalancingScheme types.String                    `tfsdk:"load_balancing_scheme"`
+	TimeoutSec          types.Int64                     `tfsdk:"timeout_sec"`
+	HealthChecks        types.List                      `tfsdk:"health_checks"`
+	SecurityPolicy      types.String                    `tfsdk:"security_policy"`
+	SessionAffinity     types.String                    `tfsdk:"session_affinity"`
+	EnableCDN           types.Bool                      `tfsdk:"enable_cdn"`
+	Backends            []*lbBackendServiceBackendModel `tfsdk:"backends"`
+	Tags                types.Map                       `tfsdk:"tags"`
 }
 
-type clientConfig struct {
-	Project     types.String `tfsdk:"project"`
-	Credentials types.String `tfsdk:"credentials"`
+type lbBackendServiceBackendModel struct {
+	Group          types.String  `tfsdk:"group"`
+	BalancingMode  types.String  `tfsdk:"balancing_mode"`
+	CapacityScaler types.Float64 `tfsdk:"capacity_scaler"`
+	MaxRate        types.Int64   `tfsdk:"max_rate"`
 }
 
+const backendServiceScopeGlobal = "global"
+
 // Metadata returns the data source backend services type name.
 func (d *LbBackendServicesDataSource) Metadata(_ context.Context,
 	req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
 	resp.TypeName = req.ProviderTypeName + "_load_balancer_backend_services"
 }
 
+// ValidateConfig rejects region when all_regions is true, since backend
+// services of all regions are listed. region with all_regions set to false is
+// valid, and unknown values are validated again once they are known.
+func (d *LbBackendServicesDataSource) ValidateConfig(ctx context.Context,
+	req datasource.ValidateConfigRequest, resp *datasource.ValidateConfigResponse) {
+	var region types.String
+	var allRegions types.Bool
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("region"), &region)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("all_regions"), &allRegions)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if allRegions.ValueBool() && !region.IsNull() && !region.IsUnknown() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("region"),
+			"Invalid Attribute Combination",
+			"region cannot be configured when all_regions is true, since backend "+
+				"services of all regions are listed.",
+		)
+	}
+}
+
 // Schema defines the schema for the backend services data source .
 func (d *LbBackendServicesDataSource) Schema(_ context.Context,
 	_ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
@@ -65,6 +112,30 @@ func (d *LbBackendServicesDataSource) Schema(_ context.Context,
 				Description: "Name of backend service to be filtered.",
 				Optional:    true,
 			},
+			"region": schema.StringAttribute{
+				Description: "Region of the regional backend services to be " +
+					"listed, or global to list global backend services, which are " +
+					"not in any region. Default to global, the region configured in " +
+					"the provider is not used.",
+				Optional: true,
+			},
+			"all_regions": schema.BoolAttribute{
+				Description: "List both global backend services and regional " +
+					"backend services of all regions, region must not be set if " +
+					"it is true. Default to false.",
+				Optional: true,
+			},
+			"tag_format": schema.StringAttribute{
+				Description: "Format of tags in descriptions of backend services, " +
+					"one of pipe_colon (key:value|key:value), kv_semicolon " +
+					"(key=value;key=value), json ({\"key\":\"value\"}) or auto, " +
+					"which detects the format of every description. Default to " +
+					"tag_format configured in the provider.",
+				Optional: true,
+				Validators: []validator.String{
+					stringvalidator.OneOf(tagcodec.Formats()...),
+				},
+			},
 			"tags": schema.MapAttribute{
 				Description: "Tags of backend service to be filtered.",
 				ElementType: types.StringType,
@@ -79,8 +150,91 @@ func (d *LbBackendServicesDataSource) Schema(_ context.Context,
 							Description: "ID of backend service.",
 							Computed:    true,
 						},
+						"name": schema.StringAttribute{
+							Description: "Name of backend service.",
+							Computed:    true,
+						},
+						"self_link": schema.StringAttribute{
+							Description: "URL of backend service.",
+							Computed:    true,
+						},
+						"scope": schema.StringAttribute{
+							Description: "Scope of backend service, either global or " +
+								"the region of a regional backend service.",
+							Computed: true,
+						},
+						"protocol": schema.StringAttribute{
+							Description: "Protocol used to communicate with backends, " +
+								"e.g. HTTP, HTTPS, HTTP2, TCP, SSL, UDP or GRPC.",
+							Computed: true,
+						},
+						"port_name": schema.StringAttribute{
+							Description: "Named port of instance groups to send " +
+								"traffic to.",
+							Computed: true,
+						},
+						"load_balancing_scheme": schema.StringAttribute{
+							Description: "Load balancing scheme of backend service, " +
+								"e.g. EXTERNAL, EXTERNAL_MANAGED, INTERNAL or " +
+								"INTERNAL_MANAGED.",
+							Computed: true,
+						},
+						"timeout_sec": schema.Int64Attribute{
+							Description: "Backend service timeout in seconds.",
+							Computed:    true,
+						},
+						"health_checks": schema.ListAttribute{
+							Description: "URLs of health checks of backend service.",
+							ElementType: types.StringType,
+							Computed:    true,
+						},
+						"security_policy": schema.StringAttribute{
+							Description: "URL of Cloud Armor security policy of " +
+								"backend service.",
+							Computed: true,
+						},
+						"session_affinity": schema.StringAttribute{
+							Description: "Session affinity of backend service, " +
+								"e.g. NONE or CLIENT_IP.",
+							Computed: true,
+						},
+						"enable_cdn": schema.BoolAttribute{
+							Description: "Whether Cloud CDN is enabled.",
+							Computed:    true,
+						},
+						"backends": schema.ListNestedAttribute{
+							Description: "Backends of backend service.",
+							Computed:    true,
+							NestedObject: schema.NestedAttributeObject{
+								Attributes: map[string]schema.Attribute{
+									"group": schema.StringAttribute{
+										Description: "URL of instance group or network " +
+											"endpoint group.",
+										Computed: true,
+									},
+									"balancing_mode": schema.StringAttribute{
+										Description: "Balancing mode of backend, " +
+											"e.g. UTILIZATION, RATE or CONNECTION.",
+										Computed: true,
+									},
+									"capacity_scaler": schema.Float64Attribute{
+										Description: "Multiplier of the capacity " +
+											"of backend, from 0.0 to 1.0.",
+										Computed: true,
+									},
+									"max_rate": schema.Int64Attribute{
+										Description: "Maximum requests per second " +
+											"of backend.",
+										Computed: true,
+									},
+								},
+							},
+						},
 						"tags": schema.MapAttribute{
-							Description: "Tags of backend service.",
+							Description: "Tags of backend service, decoded from its " +
+								"description in tag_format. Separators and \\ in keys " +
+								"and values of pipe_colon and kv_semicolon are escaped " +
+								"with \\. Malformed tags are skipped with warnings.",
 							ElementType: types.StringType,
 							Computed:    true,
 						},
@@ -89,23 +243,7 @@ func (d *LbBackendServicesDataSource) Schema(_ context.Context,
 			},
 		},
 		Blocks: map[string]schema.Block{
-			"client_config": schema.SingleNestedBlock{
-				Description: "Config to override default client created in Provider. " +
-					"This block will not be recorded in state file.",
-				Attributes: map[string]schema.Attribute{
-					"project": schema.StringAttribute{
-						Description: "Project Name for Google Cloud API. Default " +
-							"to use project configured in the provider.",
-						Optional: true,
-					},
-					"credentials": schema.StringAttribute{
-						Description: "The credentials of service account in JSON format " +
-							" Default to use credentials configured in the provider.",
-						Optional:  true,
-						Sensitive: true,
-					},
-				},
-			},
+			"client_config": dataSourceClientConfigBlock(),
 		},
 	}
 }
@@ -117,8 +255,7 @@ func (d *LbBackendServicesDataSource) Configure(_ context.Context,
 		return
 	}
 
-	d.project = req.ProviderData.(*gcpClients).project
-	d.client = req.ProviderData.(*gcpClients).computeClient
+	d.clients = req.ProviderData.(*gcpClients)
 }
 
 // Read backend services data source information
@@ -131,22 +268,10 @@ func (d *LbBackendServicesDataSource) Read(ctx context.Context,
 		return
 	}
 
-	if plan.ClientConfig == nil {
-		plan.ClientConfig = &clientConfig{}
-	}
-
-	initClient := false
-	project := plan.ClientConfig.Project.ValueString()
-	credentials := plan.ClientConfig.Credentials.ValueString()
-	if project != "" || credentials != "" {
-		initClient = true
-	}
-
-	if initClient {
-		err := d.initClient(ctx, project, credentials, resp)
-		if err != nil {
-			return
-		}
+	clients, diags := d.clients.clientsFor(ctx, plan.ClientConfig)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
 	}
 
 	// Initialize input into state
@@ -166,12 +291,15 @@ func (d *LbBackendServicesDataSource) Read(ctx context.Context,
 	// If the key is not found or the tag value is not matched,
 	// then break the checking and continue to next backend service.
 	// }
-	err := d.runBackendServices(ctx, resp, plan, state)
+	err := d.runBackendServices(ctx, resp, clients, plan, state)
 	if err != nil {
 		return
 	}
 
 	state.Name = plan.Name
+	state.Region = plan.Region
+	state.AllRegions = plan.AllRegions
+	state.TagFormat = plan.TagFormat
 	state.Tags = plan.Tags
 
 	diags = resp.State.Set(ctx, &state)
@@ -182,90 +310,153 @@ func (d *LbBackendServicesDataSource) Read(ctx context.Context,
 }
 
 func (d *LbBackendServicesDataSource) runBackendServices(ctx context.Context,
-	resp *datasource.ReadResponse, plan *LbBackendServicesDataSourceModel,
+	resp *datasource.ReadResponse, clients *gcpClients,
+	plan *LbBackendServicesDataSourceModel,
 	state *LbBackendServicesDataSourceModel) error {
-	responseByList := d.client.BackendServices.List(d.project)
-	if err := responseByList.Pages(
-		ctx,
-		func(page *googleComputeClient.BackendServiceList) error {
-			for _, backendService := range page.Items {
-
-				slbTags := make(map[string]attr.Value)
-				slbTagsTfType := types.MapNull(types.StringType)
-
-				if backendService.Description != "" {
-					tags := strings.Split(backendService.Description, "|")
-					for _, tag := range tags {
-						t := strings.Split(tag, ":")
-						slbTags[t[0]] = types.StringValue(t[1])
-					}
+	tagFormat := clients.tagFormat
+	if !plan.TagFormat.IsNull() {
+		tagFormat = tagcodec.Format(plan.TagFormat.ValueString())
+	}
 
-					var convertMapDiags diag.Diagnostics
-					slbTagsTfType, convertMapDiags = types.MapValue(types.StringType, slbTags)
-					resp.Diagnostics.Append(convertMapDiags...)
-					if resp.Diagnostics.HasError() {
-						return fmt.Errorf("[INTERNAL ERROR] Failed to convert description to tags")
-					}
-				}
+	addBackendService := func(scope string, backendService *googleComputeClient.BackendService) error {
+		slbTags := make(map[string]attr.Value)
+		slbTagsTfType := types.MapNull(types.StringType)
 
-				serviceItem := &lbBackendServicesItemModel{
-					ID:   types.Int64Value(int64(backendService.Id)),
-					Tags: slbTagsTfType,
-				}
+		// Descriptions not written by the provider may be free text, so
+		// malformed tags are skipped with warnings instead of failing.
+		tags, warnings, _ := tagcodec.Decode(backendService.Description, tagFormat, tagcodec.Lenient)
+		if len(tags) > 0 {
+			for key, value := range tags {
+				slbTags[key] = types.StringValue(value)
+			}
 
-				if !(plan.Name.IsUnknown() || plan.Name.IsNull()) && plan.Name.ValueString() != backendService.Name {
-					continue
-				}
+			var convertMapDiags diag.Diagnostics
+			slbTagsTfType, convertMapDiags = types.MapValue(types.StringType, slbTags)
+			resp.Diagnostics.Append(convertMapDiags...)
+			if resp.Diagnostics.HasError() {
+				return fmt.Errorf("[INTERNAL ERROR] Failed to convert description to tags")
+			}
+		}
 
-				if !(plan.Tags.IsUnknown() || plan.Tags.IsNull()) {
+		// a nil slice would be converted to a null list.
+		healthChecks, convertListDiags := types.ListValueFrom(ctx, types.StringType,
+			append([]string{}, backendService.HealthChecks...))
+		resp.Diagnostics.Append(convertListDiags...)
+		if resp.Diagnostics.HasError() {
+			return fmt.Errorf("[INTERNAL ERROR] Failed to convert health checks")
+		}
+		backends := []*lbBackendServiceBackendModel{}
+		for _, backend := range backendService.Backends {
+			backends = append(backends, &lbBackendServiceBackendModel{
+				Group:          types.StringValue(backend.Group),
+				BalancingMode:  types.StringValue(backend.BalancingMode),
+				CapacityScaler: types.Float64Value(backend.CapacityScaler),
+				MaxRate:        types.Int64Value(backend.MaxRate),
+			})
+		}
 
-					matched := true
-					goInputMap := plan.Tags.Elements()
-					for inputKey, inputValue := range goInputMap {
-						value, ok := slbTags[inputKey]
+		serviceItem := &lbBackendServicesItemModel{
+			ID:                  types.Int64Value(int64(backendService.Id)),
+			Name:                types.StringValue(backendService.Name),
+			SelfLink:            types.StringValue(backendService.SelfLink),
+			Scope:               types.StringValue(scope),
+			Protocol:            types.StringValue(backendService.Protocol),
+			PortName:            types.StringValue(backendService.PortName),
+			LoadBalancingScheme: types.StringValue(backendService.LoadBalancingScheme),
+			TimeoutSec:          types.Int64Value(backendService.TimeoutSec),
+			HealthChecks:        healthChecks,
+			SecurityPolicy:      types.StringValue(backendService.SecurityPolicy),
+			SessionAffinity:     types.StringValue(backendService.SessionAffinity),
+			EnableCDN:           types.BoolValue(backendService.EnableCDN),
+			Backends:            backends,
+			Tags:                slbTagsTfType,
+		}
 
-						if !ok || value != inputValue {
-							matched = false
-							break
-						}
-					}
-					if !matched {
-						continue
-					}
-				}
+		if !(plan.Name.IsUnknown() || plan.Name.IsNull()) && plan.Name.ValueString() != backendService.Name {
+			return nil
+		}
+
+		if !(plan.Tags.IsUnknown() || plan.Tags.IsNull()) {
 
-				state.Items = append(state.Items, serviceItem)
+			matched := true
+			goInputMap := plan.Tags.Elements()
+			for inputKey, inputValue := range goInputMap {
+				value, ok := slbTags[inputKey]
+
+				if !ok || value != inputValue {
+					matched = false
+					break
+				}
+			}
+			if !matched {
+				return nil
 			}
+		}
 
-			return nil
-		},
-	); err != nil {
-		resp.Diagnostics.AddError(
-			"[API ERROR] Failed to list load balancer backend services.",
-			err.Error(),
-		)
-		return err
+		// only warn about the backend services in the result.
+		for _, warning := range warnings {
+			resp.Diagnostics.AddWarning(
+				"Malformed tags in description of backend service",
+				fmt.Sprintf("Backend service %s in %s: %s", backendService.Name, scope, warning),
+			)
+		}
+		state.Items = append(state.Items, serviceItem)
+		return nil
 	}
-	return nil
-}
 
-func (d *LbBackendServicesDataSource) initClient(ctx context.Context,
-	project string, credentials string, resp *datasource.ReadResponse) error {
-	if project != "" {
-		d.project = project
+	region := plan.Region.ValueString()
+	var err error
+	switch {
+	case plan.AllRegions.ValueBool():
+		err = clients.computeClient.BackendServices.AggregatedList(clients.project).Pages(
+			ctx,
+			func(page *googleComputeClient.BackendServiceAggregatedList) error {
+				// scopes are sorted to keep the order of items stable.
+				for _, key := range slices.Sorted(maps.Keys(page.Items)) {
+					// keys are either "global" or "regions/<region>".
+					scope := strings.TrimPrefix(key, "regions/")
+					for _, backendService := range page.Items[key].BackendServices {
+						if err := addBackendService(scope, backendService); err != nil {
+							return err
+						}
+					}
+				}
+				return nil
+			},
+		)
+	case region != "" && region != backendServiceScopeGlobal:
+		err = clients.computeClient.RegionBackendServices.List(clients.project, region).Pages(
+			ctx,
+			func(page *googleComputeClient.BackendServiceList) error {
+				for _, backendService := range page.Items {
+					if err := addBackendService(region, backendService); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		)
+	default:
+		err = clients.computeClient.BackendServices.List(clients.project).Pages(
+			ctx,
+			func(page *googleComputeClient.BackendServiceList) error {
+				for _, backendService := range page.Items {
+					if err := addBackendService(backendServiceScopeGlobal, backendService); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		)
 	}
-	if credentials != "" {
-		googleClientOption := option.WithCredentialsJSON([]byte(credentials))
-		var err error
-		d.client, err = googleComputeClient.NewService(ctx, googleClientOption)
-		if err != nil {
+	if err != nil {
+		if !resp.Diagnostics.HasError() {
 			resp.Diagnostics.AddError(
-				"[API ERROR] Failed to Reinitialize Google Cloud client",
-				"Please make sure the credentials is valid.\n"+
-					"Additional error message: "+err.Error(),
+				"[API ERROR] Failed to list load balancer backend services.",
+				clients.apiErrorDetail(err),
 			)
-			return err
 		}
+		return err
 	}
 	return nil
 }
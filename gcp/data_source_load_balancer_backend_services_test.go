@@ -0,0 +1,75 @@
+package gcp
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	googleComputeClient "google.golang.org/api/compute/v1"
+)
+
+// TestLbBackendServicesRegion checks that global backend services are listed
+// unless region is set on the data source, whatever the provider region is.
+func TestLbBackendServicesRegion(t *testing.T) {
+	const typeName = "st-gcp_load_balancer_backend_services"
+	compute := newFakeComputeBackendServices(t, map[string]*googleComputeClient.BackendService{
+		"global/backendServices/web":           {Name: "web"},
+		"regions/us-east1/backendServices/web": {Name: "web"},
+	})
+	p := newTestProvider(t, map[string]tftypes.Value{
+		"compute_custom_endpoint": tftypes.NewValue(tftypes.String, compute.URL+"/compute/v1/"),
+		"region":                  tftypes.NewValue(tftypes.String, "europe-west1"),
+		"skip_region_validation":  tftypes.NewValue(tftypes.Bool, true),
+	})
+	tests := []struct {
+		region    string
+		wantScope string
+	}{
+		{"", "global"},
+		{"global", "global"},
+		{"us-east1", "us-east1"},
+	}
+	for _, tt := range tests {
+		attrs := map[string]tftypes.Value{}
+		if tt.region != "" {
+			attrs["region"] = tftypes.NewValue(tftypes.String, tt.region)
+		}
+		state := p.readDataSource(typeName, p.object(p.dataSourceType(typeName), attrs))
+		var items []tftypes.Value
+		if err := attrValue(t, state, "items").As(&items); err != nil {
+			t.Fatal(err)
+		}
+		if len(items) != 1 || attrString(t, items[0], "scope") != tt.wantScope {
+			t.Errorf("region %q: items = %v, want the backend service in %s", tt.region, items, tt.wantScope)
+		}
+	}
+}
+
+func TestLbBackendServicesRegionConflictsWithAllRegions(t *testing.T) {
+	const typeName = "st-gcp_load_balancer_backend_services"
+	tests := []struct {
+		name       string
+		region     tftypes.Value
+		allRegions tftypes.Value
+		wantError  bool
+	}{
+		{"region", tftypes.NewValue(tftypes.String, "us-east1"), tftypes.NewValue(tftypes.Bool, nil), false},
+		{"region without all_regions", tftypes.NewValue(tftypes.String, "us-east1"), tftypes.NewValue(tftypes.Bool, false), false},
+		{"all_regions", tftypes.NewValue(tftypes.String, nil), tftypes.NewValue(tftypes.Bool, true), false},
+		{"region with all_regions", tftypes.NewValue(tftypes.String, "us-east1"), tftypes.NewValue(tftypes.Bool, true), true},
+		{"unknown region with all_regions", tftypes.NewValue(tftypes.String, tftypes.UnknownValue), tftypes.NewValue(tftypes.Bool, true), false},
+		{"region with unknown all_regions", tftypes.NewValue(tftypes.String, "us-east1"), tftypes.NewValue(tftypes.Bool, tftypes.UnknownValue), false},
+	}
+	p := newTestProvider(t, nil)
+	typ := p.dataSourceType(typeName)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diagnostics := p.validateDataSource(typeName, p.object(typ, map[string]tftypes.Value{
+				"region":      tt.region,
+				"all_regions": tt.allRegions,
+			}))
+			if got := hasError(diagnostics, "Invalid Attribute Combination"); got != tt.wantError {
+				t.Errorf("error = %v, want %v: %v", got, tt.wantError, diagnostics)
+			}
+		})
+	}
+}
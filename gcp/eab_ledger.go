@@ -0,0 +1,90 @@
+package gcp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+const defaultEabLedgerPath = "~/.terraform.d/st-gcp/acme_eab_ledger.jsonl"
+
+// eabLedgerMutex serializes writes of the ledger within the provider process,
+// since resources are created concurrently.
+var eabLedgerMutex sync.Mutex
+
+// eabLedgerEntry is a line of the EAB ledger. The HMAC is never recorded.
+type eabLedgerEntry struct {
+	Project     string `json:"project"`
+	Location    string `json:"location"`
+	Environment string `json:"environment"`
+	KeyID       string `json:"key_id"`
+	Name        string `json:"name"`
+	CreateAt    int64  `json:"create_at"`
+}
+
+// appendEabLedger records a created EAB in the ledger, which is a JSON Lines
+// file kept by the provider since Public CA API can not list EABs.
+func appendEabLedger(ledgerPath string, entry eabLedgerEntry) error {
+	ledgerPath, err := homedir.Expand(ledgerPath)
+	if err != nil {
+		return fmt.Errorf("failed to expand homedir of EAB ledger: %v", err)
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal EAB ledger entry: %v", err)
+	}
+
+	eabLedgerMutex.Lock()
+	defer eabLedgerMutex.Unlock()
+	if err := os.MkdirAll(filepath.Dir(ledgerPath), 0o700); err != nil {
+		return fmt.Errorf("failed to create directory of EAB ledger: %v", err)
+	}
+	f, err := os.OpenFile(ledgerPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open EAB ledger: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write EAB ledger: %v", err)
+	}
+	return nil
+}
+
+// readEabLedger returns the EABs recorded in the ledger for the project and
+// location. A missing ledger means no EAB has been recorded.
+func readEabLedger(ledgerPath string, project string, location string) ([]eabLedgerEntry, error) {
+	ledgerPath, err := homedir.Expand(ledgerPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand homedir of EAB ledger: %v", err)
+	}
+	f, err := os.Open(ledgerPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open EAB ledger: %v", err)
+	}
+	defer f.Close()
+
+	var entries []eabLedgerEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry eabLedgerEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal EAB ledger entry: %v", err)
+		}
+		if entry.Project == project && entry.Location == location {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read EAB ledger: %v", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,160 @@
+package gcp
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+var (
+	_ ephemeral.EphemeralResource              = &acmeEabEphemeralResource{}
+	_ ephemeral.EphemeralResourceWithConfigure = &acmeEabEphemeralResource{}
+)
+
+// acmeEabEphemeralResource Present st-gcp_acme_eab ephemeral resource
+type acmeEabEphemeralResource struct {
+	client *gcpClients
+}
+
+type acmeEabEphemeralModel struct {
+	ClientConfig *clientConfig `tfsdk:"client_config"`
+	Project      types.String  `tfsdk:"project"`
+	Location     types.String  `tfsdk:"location"`
+	Environment  types.String  `tfsdk:"environment"`
+	ApiVersion   types.String  `tfsdk:"api_version"`
+	KeyID        types.String  `tfsdk:"key_id"`
+	Name         types.String  `tfsdk:"name"`
+	HmacBase64   types.String  `tfsdk:"hmac_base64"`
+}
+
+// NewAcmeEabEphemeralResource
+func NewAcmeEabEphemeralResource() ephemeral.EphemeralResource {
+	return &acmeEabEphemeralResource{}
+}
+
+// Metadata
+func (r *acmeEabEphemeralResource) Metadata(_ context.Context,
+	req ephemeral.MetadataRequest, resp *ephemeral.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_acme_eab"
+}
+
+// Schema
+func (r *acmeEabEphemeralResource) Schema(_ context.Context,
+	_ ephemeral.SchemaRequest, resp *ephemeral.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Request EAB credential for ACME for a single Terraform run. " +
+			"A new EAB is created whenever it is opened and it is never stored in " +
+			"plan or state, so it can be passed to write-only arguments.",
+		Attributes: map[string]schema.Attribute{
+			"project": schema.StringAttribute{
+				Description: "The project to create EAB in. Default to the project " +
+					"of client_config, then the project configured in the provider.",
+				Optional: true,
+				Computed: true,
+			},
+			"location": schema.StringAttribute{
+				Description: "The location to create EAB in. Default to global.",
+				Optional:    true,
+				Computed:    true,
+			},
+			"environment": schema.StringAttribute{
+				Description: "Environment of Google Public CA, either production or " +
+					"staging. Default to production.",
+				Optional: true,
+				Computed: true,
+				Validators: []validator.String{
+					stringvalidator.OneOf(publicCAEnvironmentProduction, publicCAEnvironmentStaging),
+				},
+			},
+			"api_version": schema.StringAttribute{
+				Description: "API version of Google Public CA, either v1 or v1beta1. " +
+					"Default to v1beta1.",
+				Optional: true,
+				Computed: true,
+				Validators: []validator.String{
+					stringvalidator.OneOf("v1", "v1beta1"),
+				},
+			},
+			"key_id": schema.StringAttribute{
+				Description: "EAB key ID.",
+				Computed:    true,
+			},
+			"name": schema.StringAttribute{
+				Description: "EAB name.",
+				Computed:    true,
+			},
+			"hmac_base64": schema.StringAttribute{
+				Description: "EAB credential with hmac_base64 format.",
+				Computed:    true,
+				Sensitive:   true,
+			},
+		},
+		Blocks: map[string]schema.Block{
+			"client_config": ephemeralResourceClientConfigBlock(),
+		},
+	}
+}
+
+// Configure
+func (r *acmeEabEphemeralResource) Configure(_ context.Context,
+	req ephemeral.ConfigureRequest, resp *ephemeral.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	client, ok := req.ProviderData.(*gcpClients)
+	if !ok {
+		resp.Diagnostics.AddError("req.ProviderData not a gcpClients error", "")
+		return
+	}
+	r.client = client
+}
+
+// Open creates a new EAB credential every time it is called.
+func (r *acmeEabEphemeralResource) Open(ctx context.Context,
+	req ephemeral.OpenRequest, resp *ephemeral.OpenResponse) {
+	var model acmeEabEphemeralModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &model)...)
+	if resp.Diagnostics.HasError() {
+		tflog.Error(ctx, "Open req.Config.Get error")
+		return
+	}
+
+	state := acmeEabState{
+		Project:     model.Project,
+		Location:    model.Location,
+		Environment: model.Environment,
+		ApiVersion:  model.ApiVersion,
+	}
+	if state.Location.IsNull() {
+		state.Location = types.StringValue("global")
+	}
+	if state.Environment.IsNull() {
+		state.Environment = types.StringValue(publicCAEnvironmentProduction)
+	}
+	if state.ApiVersion.IsNull() {
+		state.ApiVersion = types.StringValue("v1beta1")
+	}
+	clients, diags := r.client.clientsFor(ctx, model.ClientConfig)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if err := createEabCred(ctx, &state, clients); err != nil {
+		resp.Diagnostics.AddError("createEabCred error", clients.apiErrorDetail(err))
+		return
+	}
+
+	model.Project = state.Project
+	model.Location = state.Location
+	model.Environment = state.Environment
+	model.ApiVersion = state.ApiVersion
+	model.KeyID = state.KeyID
+	model.Name = state.Name
+	model.HmacBase64 = state.HmacBase64
+	resp.Diagnostics.Append(resp.Result.Set(ctx, &model)...)
+}
@@ -2,46 +2,102 @@ package gcp
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/provider"
 	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 
-	"github.com/mitchellh/go-homedir"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
 	googleComputeClient "google.golang.org/api/compute/v1"
 	"google.golang.org/api/option"
+
+	"github.com/myklst/terraform-provider-st-gcp/internal/tagcodec"
+)
+
+const (
+	cloudPlatformScope    = "https://www.googleapis.com/auth/cloud-platform"
+	defaultUniverseDomain = "googleapis.com"
 )
 
 type gcpClients struct {
-	project         string
-	credentialsJSON []byte
-	computeClient   *googleComputeClient.Service
+	region                  string
+	zone                    string
+	project                 string
+	tokenSource             oauth2.TokenSource
+	baseTokenSource         oauth2.TokenSource // tokenSource before impersonation
+	accessToken             bool               // whether tokenSource serves a raw access token
+	iamCredentialsEndpoint  string
+	computeEndpoint         string
+	publicCAEndpoint        string
+	publicCAStagingEndpoint string
+	dnsEndpoint             string
+	eabLedgerPath           string
+	tagFormat               tagcodec.Format
+	retry                   retryPolicy
+	userAgent               string
+	httpClient              *http.Client
+	computeClient           *googleComputeClient.Service
+	cache                   *clientCache
 }
 
 // Ensure the implementation satisfies the expected interfaces
 var (
-	_ provider.Provider = &googleCloudProvider{}
+	_ provider.Provider                       = &googleCloudProvider{}
+	_ provider.ProviderWithEphemeralResources = &googleCloudProvider{}
 )
 
 // New is a helper function to simplify provider server
-func New() provider.Provider {
-	return &googleCloudProvider{}
+func New(version string) func() provider.Provider {
+	return func() provider.Provider {
+		return &googleCloudProvider{
+			version: version,
+		}
+	}
 }
 
-type googleCloudProvider struct{}
+type googleCloudProvider struct {
+	// version is the provider version set by the release build, or "dev".
+	version string
+}
 
 type googleCloudProviderModel struct {
-	Project     types.String `tfsdk:"project"`
-	Credentials types.String `tfsdk:"credentials"`
+	Project                            types.String `tfsdk:"project"`
+	Region                             types.String `tfsdk:"region"`
+	Zone                               types.String `tfsdk:"zone"`
+	SkipRegionValidation               types.Bool   `tfsdk:"skip_region_validation"`
+	Credentials                        types.String `tfsdk:"credentials"`
+	AccessToken                        types.String `tfsdk:"access_token"`
+	ImpersonateServiceAccount          types.String `tfsdk:"impersonate_service_account"`
+	ImpersonateServiceAccountDelegates types.List   `tfsdk:"impersonate_service_account_delegates"`
+	UniverseDomain                     types.String `tfsdk:"universe_domain"`
+	IamCredentialsCustomEndpoint       types.String `tfsdk:"iam_credentials_custom_endpoint"`
+	ComputeCustomEndpoint              types.String `tfsdk:"compute_custom_endpoint"`
+	PublicCACustomEndpoint             types.String `tfsdk:"publicca_custom_endpoint"`
+	DNSCustomEndpoint                  types.String `tfsdk:"dns_custom_endpoint"`
+	AcmeEabLedgerPath                  types.String `tfsdk:"acme_eab_ledger_path"`
+	TagFormat                          types.String `tfsdk:"tag_format"`
+	RequestTimeout                     types.String `tfsdk:"request_timeout"`
+	MaxRetries                         types.Int64  `tfsdk:"max_retries"`
 }
 
 // Metadata returns the provider type name.
 func (p *googleCloudProvider) Metadata(_ context.Context, _ provider.MetadataRequest, resp *provider.MetadataResponse) {
 	resp.TypeName = "st-gcp"
+	resp.Version = p.version
 }
 
 // Schema defines the provider-level schema for configuration data.
@@ -53,19 +109,133 @@ func (p *googleCloudProvider) Schema(_ context.Context, _ provider.SchemaRequest
 		Attributes: map[string]schema.Attribute{
 			"project": schema.StringAttribute{
 				Description: "Project Name for Google Cloud API. May also be provided " +
-					"via GOOGLE_PROJECT environment variable.",
+					"via GOOGLE_PROJECT environment variable. Default to the project " +
+					"of the credentials if it can be detected.",
+				Optional: true,
+			},
+			"region": schema.StringAttribute{
+				Description: "The default region for regional resources, e.g. " +
+					"region of st-gcp_load_balancer_backend_service_tags, which " +
+					"accepts global to select a global backend service instead. May " +
+					"also be provided via GOOGLE_REGION environment variable. Default " +
+					"to the region of zone if it is set.",
+				Optional: true,
+			},
+			"zone": schema.StringAttribute{
+				Description: "The default zone for zonal resources and data sources. " +
+					"May also be provided via GOOGLE_ZONE environment variable.",
+				Optional: true,
+			},
+			"skip_region_validation": schema.BoolAttribute{
+				Description: "Skip validating region and zone against the regions " +
+					"of the project listed by Compute Engine API, which requires " +
+					"compute.regions.list permission. They are only validated when " +
+					"set in the provider block, never when provided via environment " +
+					"variables. Default to false.",
 				Optional: true,
 			},
 			"credentials": schema.StringAttribute{
-				Description: "Either the path to or the contents of a service account " +
-					"key file in JSON format for Google Cloud API. May also be " +
+				Description: "Either the path to or the contents of a credentials " +
+					"file in JSON format for Google Cloud API, which can be a service " +
+					"account key, an authorized user credentials or an external " +
+					"account (Workload Identity Federation) credential configuration " +
+					"such as GitHub OIDC or AWS federation. May also be " +
 					"provided via GOOGLE_CREDENTIALS environment variable environment " +
 					"variable, or generate a service account key file and set the " +
 					"GOOGLE_APPLICATION_CREDENTIALS environment variable to the " +
-					"path of the JSON file.",
+					"path of the JSON file. If none of them is set, Application " +
+					"Default Credentials will be used, e.g. the credentials of " +
+					"`gcloud auth application-default login` or the GCE metadata server.",
 				Optional:  true,
 				Sensitive: true,
 			},
+			"access_token": schema.StringAttribute{
+				Description: "A temporary OAuth 2.0 access token for Google Cloud " +
+					"API, used instead of credentials. The token can not be " +
+					"refreshed by the provider, so it must be valid for the whole " +
+					"run. May also be provided via GOOGLE_OAUTH_ACCESS_TOKEN " +
+					"environment variable.",
+				Optional:  true,
+				Sensitive: true,
+			},
+			"impersonate_service_account": schema.StringAttribute{
+				Description: "The service account to impersonate for all Google Cloud " +
+					"API calls. The credentials must be granted " +
+					"roles/iam.serviceAccountTokenCreator on it. May also be " +
+					"provided via GOOGLE_IMPERSONATE_SERVICE_ACCOUNT environment variable.",
+				Optional: true,
+			},
+			"impersonate_service_account_delegates": schema.ListAttribute{
+				Description: "The delegation chain for impersonating the service " +
+					"account, each account must be granted " +
+					"roles/iam.serviceAccountTokenCreator on the next one.",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"request_timeout": schema.StringAttribute{
+				Description: "The maximum time to spend on a request of Google Cloud " +
+					"API including retries and reading the response, in Go duration " +
+					"format, e.g. 90s. Default to 60s.",
+				Optional: true,
+			},
+			"max_retries": schema.Int64Attribute{
+				Description: "The maximum number of retries of a request which " +
+					"fails with network errors, 429 or 5xx responses except 501. POST " +
+					"and PATCH requests, e.g. creating an EAB, are only retried on 429 " +
+					"and failures to connect, since they may have been processed. Default to 3.",
+				Optional: true,
+				Validators: []validator.Int64{
+					int64validator.AtLeast(0),
+				},
+			},
+			"tag_format": schema.StringAttribute{
+				Description: "The default format of tags in descriptions of " +
+					"resources, one of pipe_colon (key:value|key:value), " +
+					"kv_semicolon (key=value;key=value), json ({\"key\":\"value\"}) " +
+					"or auto, which detects the format of every description. " +
+					"Default to pipe_colon.",
+				Optional: true,
+				Validators: []validator.String{
+					stringvalidator.OneOf(tagcodec.Formats()...),
+				},
+			},
+			"acme_eab_ledger_path": schema.StringAttribute{
+				Description: "Path of the local ledger recording every EAB created " +
+					"by the provider without the HMAC, which is read by " +
+					"st-gcp_acme_eabs data source. Default to " +
+					defaultEabLedgerPath + ".",
+				Optional: true,
+			},
+			"universe_domain": schema.StringAttribute{
+				Description: "The universe domain of Google Cloud APIs, the default " +
+					"endpoint of every API is derived from it. Default to " +
+					"googleapis.com.",
+				Optional: true,
+			},
+			"iam_credentials_custom_endpoint": schema.StringAttribute{
+				Description: "Custom endpoint of IAM Credentials API used to " +
+					"impersonate service account. Default to " +
+					"https://iamcredentials.<universe_domain>/.",
+				Optional: true,
+			},
+			"compute_custom_endpoint": schema.StringAttribute{
+				Description: "Custom endpoint of Compute Engine API, e.g. a Private " +
+					"Service Connect endpoint. Default to " +
+					"https://compute.<universe_domain>/compute/v1/.",
+				Optional: true,
+			},
+			"dns_custom_endpoint": schema.StringAttribute{
+				Description: "Custom endpoint of Cloud DNS API, used to solve DNS-01 " +
+					"challenges. Default to https://dns.<universe_domain>/.",
+				Optional: true,
+			},
+			"publicca_custom_endpoint": schema.StringAttribute{
+				Description: "Custom endpoint of Public Certificate Authority API " +
+					"for both production and staging environments. Default to " +
+					"https://publicca.<universe_domain>/ and " +
+					"https://preprod-publicca.<universe_domain>/ respectively.",
+				Optional: true,
+			},
 		},
 	}
 }
@@ -94,6 +264,23 @@ func (p *googleCloudProvider) Configure(ctx context.Context, req provider.Config
 		project = os.Getenv("GOOGLE_PROJECT")
 	}
 
+	region := os.Getenv("GOOGLE_REGION")
+	if !config.Region.IsNull() {
+		region = config.Region.ValueString()
+	}
+	zone := os.Getenv("GOOGLE_ZONE")
+	if !config.Zone.IsNull() {
+		zone = config.Zone.ValueString()
+	}
+	if region == "" && zone != "" {
+		region = zoneRegion(zone)
+	}
+
+	accessToken := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
+	if !config.AccessToken.IsNull() {
+		accessToken = config.AccessToken.ValueString()
+	}
+
 	if !config.Credentials.IsNull() {
 		credential = config.Credentials.ValueString()
 	} else {
@@ -103,20 +290,154 @@ func (p *googleCloudProvider) Configure(ctx context.Context, req provider.Config
 		}
 	}
 
+	universeDomain := config.UniverseDomain.ValueString()
+	if universeDomain == "" {
+		universeDomain = defaultUniverseDomain
+	}
+	iamCredentialsEndpoint := serviceEndpoint(config.IamCredentialsCustomEndpoint,
+		"https://iamcredentials."+universeDomain+"/")
+	computeEndpoint := serviceEndpoint(config.ComputeCustomEndpoint,
+		"https://compute."+universeDomain+"/compute/v1/")
+	publicCAEndpoint := serviceEndpoint(config.PublicCACustomEndpoint,
+		"https://publicca."+universeDomain+"/")
+	publicCAStagingEndpoint := serviceEndpoint(config.PublicCACustomEndpoint,
+		"https://preprod-publicca."+universeDomain+"/")
+	dnsEndpoint := serviceEndpoint(config.DNSCustomEndpoint,
+		"https://dns."+universeDomain+"/")
+
+	retry := retryPolicy{
+		maxRetries: defaultMaxRetries,
+		timeout:    defaultRequestTimeout,
+	}
+	if !config.MaxRetries.IsNull() {
+		retry.maxRetries = int(config.MaxRetries.ValueInt64())
+	}
+	if !config.RequestTimeout.IsNull() {
+		timeout, err := time.ParseDuration(config.RequestTimeout.ValueString())
+		if err != nil || timeout <= 0 {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("request_timeout"),
+				"Invalid request timeout",
+				"The request_timeout must be a positive duration, e.g. 90s.",
+			)
+			return
+		}
+		retry.timeout = timeout
+	}
+
+	tagFormat := tagcodec.FormatPipeColon
+	if !config.TagFormat.IsNull() {
+		tagFormat = tagcodec.Format(config.TagFormat.ValueString())
+	}
+
+	eabLedgerPath := config.AcmeEabLedgerPath.ValueString()
+	if eabLedgerPath == "" {
+		eabLedgerPath = defaultEabLedgerPath
+	}
+
+	// Token sources outlive this request, so they must not be bound to
+	// the context of Configure.
+	var tokenSource oauth2.TokenSource
+	if accessToken != "" {
+		tokenSource = oauth2.StaticTokenSource(&oauth2.Token{
+			AccessToken: accessToken,
+			TokenType:   "Bearer",
+		})
+	} else if credential != "" {
+		// if this is a path and we can stat it, assume it's file
+		credentialsContent, err := loadCredentials(credential)
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"[INTERNAL ERROR] Failed to load credentials",
+				err.Error(),
+			)
+			return
+		}
+		googleCredentials, err := google.CredentialsFromJSON(context.Background(), credentialsContent, cloudPlatformScope)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("credentials"),
+				"Invalid Google Cloud API credentials",
+				"Please make sure the credentials is a valid service account key, "+
+					"authorized user or external account (Workload Identity "+
+					"Federation) credential configuration file.\n"+
+					"Additional error message: "+err.Error(),
+			)
+			return
+		}
+		tokenSource = googleCredentials.TokenSource
+		if project == "" {
+			project = googleCredentials.ProjectID
+		}
+	} else {
+		defaultCredentials, err := google.FindDefaultCredentials(context.Background(), cloudPlatformScope)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("credentials"),
+				"Missing Google Cloud API credentials",
+				"The provider cannot create the Google Cloud API client as there is a "+
+					"missing or empty value for the Google Cloud API credential and no "+
+					"Application Default Credentials can be found. Set the credential "+
+					"value in the configuration or use the GOOGLE_CREDENTIALS environment "+
+					"variable or GOOGLE_APPLICATION_CREDENTIALS environment variable, or "+
+					"run `gcloud auth application-default login`.\n"+
+					"Additional error message: "+err.Error(),
+			)
+			return
+		}
+		tokenSource = defaultCredentials.TokenSource
+		if project == "" {
+			project = defaultCredentials.ProjectID
+		}
+	}
+
+	userAgent := fmt.Sprintf("Terraform/%s (+https://www.terraform.io) terraform-provider-st-gcp/%s",
+		req.TerraformVersion, p.version)
+
+	baseTokenSource := tokenSource
+	impersonateServiceAccount := os.Getenv("GOOGLE_IMPERSONATE_SERVICE_ACCOUNT")
+	if !config.ImpersonateServiceAccount.IsNull() {
+		impersonateServiceAccount = config.ImpersonateServiceAccount.ValueString()
+	}
+	if impersonateServiceAccount != "" {
+		var delegates []string
+		resp.Diagnostics.Append(config.ImpersonateServiceAccountDelegates.ElementsAs(ctx, &delegates, false)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		tokenSource = newImpersonatedTokenSource(newHTTPClient(tokenSource, userAgent, retry),
+			iamCredentialsEndpoint, impersonateServiceAccount, delegates)
+	}
+
 	// If any of the expected configuration are missing, return
 	// errors with provider-specific guidance.
-	p.checkField(project, resp, credential)
+	p.checkField(project, resp)
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	// if this is a path and we can stat it, assume it's file
-	credentialsContent := p.loadFromFile(resp, credential)
-	if credentialsContent == nil {
-		return
+	clients := gcpClients{
+		region:                  region,
+		zone:                    zone,
+		project:                 project,
+		tokenSource:             tokenSource,
+		baseTokenSource:         baseTokenSource,
+		accessToken:             accessToken != "",
+		iamCredentialsEndpoint:  iamCredentialsEndpoint,
+		computeEndpoint:         computeEndpoint,
+		publicCAEndpoint:        publicCAEndpoint,
+		publicCAStagingEndpoint: publicCAStagingEndpoint,
+		dnsEndpoint:             dnsEndpoint,
+		eabLedgerPath:           eabLedgerPath,
+		tagFormat:               tagFormat,
+		retry:                   retry,
+		userAgent:               userAgent,
+		httpClient:              newHTTPClient(tokenSource, userAgent, retry),
+		cache:                   newClientCache(),
 	}
-	googleClientOption := option.WithCredentialsJSON(credentialsContent)
-	computeService, err := googleComputeClient.NewService(ctx, googleClientOption)
+
+	computeService, err := googleComputeClient.NewService(ctx,
+		option.WithHTTPClient(clients.httpClient), option.WithEndpoint(computeEndpoint))
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"[API ERROR] Failed to initialize Google Cloud client",
@@ -127,53 +448,96 @@ func (p *googleCloudProvider) Configure(ctx context.Context, req provider.Config
 	if resp.Diagnostics.HasError() {
 		return
 	}
-	clients := gcpClients{
-		project:         project,
-		credentialsJSON: credentialsContent,
-		computeClient:   computeService,
+	clients.computeClient = computeService
+
+	// region and zone from environment variables are not validated, so that
+	// users who never configure them, e.g. only creating EABs, do not need
+	// compute.regions.list permission.
+	if !config.SkipRegionValidation.ValueBool() && (!config.Region.IsNull() || !config.Zone.IsNull()) {
+		p.validateRegion(ctx, &clients, resp)
+		if resp.Diagnostics.HasError() {
+			return
+		}
 	}
+
 	resp.DataSourceData = &clients
 	resp.ResourceData = &clients
+	resp.EphemeralResourceData = &clients
 }
 
-// nolint:lll
-func (*googleCloudProvider) loadFromFile(resp *provider.ConfigureResponse, credential string) []byte {
-	/*
-		Check whether the credentials is a file as it support either the path to
-		or the contents of a service account key file in JSON format.
-		reference:
-		- https://github.com/hashicorp/terraform-provider-google/blob/80f6dd2fcc1c209ed2b066d9b758db2e34145368/google/path_or_contents.go
-	*/
-	credentialAbsPath := credential
-	if credential[0:1] == "~" {
-		var err error
-		credentialAbsPath, err = homedir.Expand(credential)
-		if err != nil {
-			resp.Diagnostics.AddError(
-				"[INTERNAL ERROR] Failed to expand homedir of credentials file",
-				err.Error(),
-			)
-			return nil
-		}
+// serviceEndpoint returns the custom endpoint if it is configured, otherwise
+// the default one. Endpoints always end with a slash, so that API paths can
+// be appended directly.
+func serviceEndpoint(customEndpoint types.String, defaultEndpoint string) string {
+	endpoint := customEndpoint.ValueString()
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+	if !strings.HasSuffix(endpoint, "/") {
+		endpoint += "/"
 	}
+	return endpoint
+}
 
-	var credentialContent []byte
-	if _, err := os.Stat(credentialAbsPath); err == nil {
-		credentialContent, err = os.ReadFile(credentialAbsPath)
-		if err != nil {
-			resp.Diagnostics.AddError(
-				"[INTERNAL ERROR] Failed to read credentials file",
-				err.Error(),
-			)
+// validateRegion checks the region and zone against the regions of the project,
+// so that a typo fails at Configure instead of every request.
+func (*googleCloudProvider) validateRegion(ctx context.Context, clients *gcpClients, resp *provider.ConfigureResponse) {
+	if clients.region == "" && clients.zone == "" {
+		return
+	}
+
+	// zones of every region, keyed by the region name.
+	regions := map[string][]string{}
+	err := clients.computeClient.Regions.List(clients.project).Pages(ctx,
+		func(page *googleComputeClient.RegionList) error {
+			for _, region := range page.Items {
+				// zones are URLs of the zone resources.
+				for _, zone := range region.Zones {
+					regions[region.Name] = append(regions[region.Name], zone[strings.LastIndex(zone, "/")+1:])
+				}
+				if _, ok := regions[region.Name]; !ok {
+					regions[region.Name] = nil
+				}
+			}
 			return nil
-		}
-	} else {
-		credentialContent = []byte(credential)
+		})
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"[API ERROR] Failed to list regions to validate region and zone",
+			clients.apiErrorDetail(err)+"\n\nSet skip_region_validation to true "+
+				"if the credentials are not allowed to list regions.",
+		)
+		return
+	}
+
+	zones, ok := regions[clients.region]
+	if clients.region != "" && !ok {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("region"),
+			"Invalid Google Cloud region",
+			fmt.Sprintf("The region %s is not available in project %s.", clients.region, clients.project),
+		)
+		return
+	}
+	if clients.zone != "" && !slices.Contains(zones, clients.zone) {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("zone"),
+			"Invalid Google Cloud zone",
+			fmt.Sprintf("The zone %s is not available in region %s of project %s.",
+				clients.zone, clients.region, clients.project),
+		)
 	}
-	return credentialContent
 }
 
-func (*googleCloudProvider) checkField(project string, resp *provider.ConfigureResponse, credentials string) {
+// zoneRegion returns the region of the zone, e.g. us-central1 of us-central1-a.
+func zoneRegion(zone string) string {
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		return zone[:i]
+	}
+	return zone
+}
+
+func (*googleCloudProvider) checkField(project string, resp *provider.ConfigureResponse) {
 	if project == "" {
 		resp.Diagnostics.AddAttributeError(
 			path.Root("project"),
@@ -185,18 +549,6 @@ func (*googleCloudProvider) checkField(project string, resp *provider.ConfigureR
 				"is not empty.",
 		)
 	}
-
-	if credentials == "" {
-		resp.Diagnostics.AddAttributeError(
-			path.Root("credentials"),
-			"Missing Google Cloud API credentials",
-			"The provider cannot create the Google Cloud API client as there is a "+
-				"missing or empty value for the Google Cloud API credential. Set the "+
-				"credential value in the configuration or use the GOOGLE_CREDENTIALS "+
-				"environment variable or GOOGLE_APLLICATION_CREDENTIALS environment "+
-				"variable. If either is already set, ensure the value is not empty.",
-		)
-	}
 }
 
 func (*googleCloudProvider) checkConfig(config *googleCloudProviderModel, resp *provider.ConfigureResponse) {
@@ -211,6 +563,17 @@ func (*googleCloudProvider) checkConfig(config *googleCloudProviderModel, resp *
 		)
 	}
 
+	if config.Region.IsUnknown() || config.Zone.IsUnknown() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("region"),
+			"Unknown Google Cloud region or zone",
+			"The provider cannot create the Google Cloud API client as there is "+
+				"an unknown configuration value for the Google Cloud region or zone. "+
+				"Set the value statically in the configuration, or use the "+
+				"GOOGLE_REGION or GOOGLE_ZONE environment variable.",
+		)
+	}
+
 	if config.Credentials.IsUnknown() {
 		resp.Diagnostics.AddAttributeError(
 			path.Root("credentials"),
@@ -223,12 +586,36 @@ func (*googleCloudProvider) checkConfig(config *googleCloudProviderModel, resp *
 				"to the path of the JSON file.",
 		)
 	}
+
+	if config.AccessToken.IsUnknown() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("access_token"),
+			"Unknown Google Cloud access token",
+			"The provider cannot create the Google Cloud API client as there is "+
+				"an unknown configuration value for the Google Cloud access token. "+
+				"Set the value statically in the configuration, or use the "+
+				"GOOGLE_OAUTH_ACCESS_TOKEN environment variable.",
+		)
+	}
+
+	if config.ImpersonateServiceAccount.IsUnknown() ||
+		config.ImpersonateServiceAccountDelegates.IsUnknown() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("impersonate_service_account"),
+			"Unknown Google Cloud service account to impersonate",
+			"The provider cannot create the Google Cloud API client as there is "+
+				"an unknown configuration value for the service account to impersonate "+
+				"or its delegates. Set the value statically in the configuration, or "+
+				"use the GOOGLE_IMPERSONATE_SERVICE_ACCOUNT environment variable.",
+		)
+	}
 }
 
 // DataSources
 func (p *googleCloudProvider) DataSources(_ context.Context) []func() datasource.DataSource {
 	return []func() datasource.DataSource{
 		NewLbBackendServicesDataSource,
+		NewAcmeEabsDataSource,
 	}
 }
 
@@ -236,5 +623,15 @@ func (p *googleCloudProvider) DataSources(_ context.Context) []func() datasource
 func (p *googleCloudProvider) Resources(_ context.Context) []func() resource.Resource {
 	return []func() resource.Resource{
 		NewAcmeEabResource,
+		NewAcmeRegistrationResource,
+		NewPublicCACertificateResource,
+		NewLbBackendServiceTagsResource,
+	}
+}
+
+// EphemeralResources
+func (p *googleCloudProvider) EphemeralResources(_ context.Context) []func() ephemeral.EphemeralResource {
+	return []func() ephemeral.EphemeralResource{
+		NewAcmeEabEphemeralResource,
 	}
 }
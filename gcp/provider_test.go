@@ -0,0 +1,373 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+// testProvider drives the provider through the plugin protocol as Terraform
+// does, so that defaults, plan modifiers and replacements are exercised
+// without a Terraform binary.
+type testProvider struct {
+	t      *testing.T
+	server tfprotov6.ProviderServer
+	schema *tfprotov6.GetProviderSchemaResponse
+}
+
+// newTestProvider configures the provider with an access token, a project
+// and a ledger in a temporary directory, which can be overridden by config.
+func newTestProvider(t *testing.T, config map[string]tftypes.Value) *testProvider {
+	t.Helper()
+	// the environment of the developer must not leak into the tests.
+	for _, name := range []string{
+		"GOOGLE_PROJECT", "GOOGLE_REGION", "GOOGLE_ZONE",
+		"GOOGLE_CREDENTIALS", "GOOGLE_APPLICATION_CREDENTIALS",
+		"GOOGLE_OAUTH_ACCESS_TOKEN", "GOOGLE_IMPERSONATE_SERVICE_ACCOUNT",
+	} {
+		t.Setenv(name, "")
+	}
+
+	p := &testProvider{t: t, server: providerserver.NewProtocol6(New("test")())()}
+	schema, err := p.server.GetProviderSchema(context.Background(), &tfprotov6.GetProviderSchemaRequest{})
+	if err != nil {
+		t.Fatalf("GetProviderSchema: %v", err)
+	}
+	p.checkDiagnostics("GetProviderSchema", schema.Diagnostics)
+	p.schema = schema
+	p.checkDiagnostics("ConfigureProvider", p.configure(config))
+	return p
+}
+
+// configure configures the provider with config over the defaults of
+// newTestProvider.
+func (p *testProvider) configure(config map[string]tftypes.Value) []*tfprotov6.Diagnostic {
+	p.t.Helper()
+	attrs := map[string]tftypes.Value{
+		"access_token":         tftypes.NewValue(tftypes.String, "test-token"),
+		"project":              tftypes.NewValue(tftypes.String, "test-project"),
+		"acme_eab_ledger_path": tftypes.NewValue(tftypes.String, filepath.Join(p.t.TempDir(), "acme_eab_ledger.jsonl")),
+	}
+	for name, value := range config {
+		attrs[name] = value
+	}
+	resp, err := p.server.ConfigureProvider(context.Background(), &tfprotov6.ConfigureProviderRequest{
+		TerraformVersion: "1.9.0",
+		Config:           p.dynamicValue(p.object(p.schemaType(p.schema.Provider), attrs)),
+	})
+	if err != nil {
+		p.t.Fatalf("ConfigureProvider: %v", err)
+	}
+	return resp.Diagnostics
+}
+
+// resourceType returns the object type of the resource.
+func (p *testProvider) resourceType(typeName string) tftypes.Object {
+	return p.schemaType(p.resourceSchema(typeName))
+}
+
+// dataSourceType returns the object type of the data source.
+func (p *testProvider) dataSourceType(typeName string) tftypes.Object {
+	schema, ok := p.schema.DataSourceSchemas[typeName]
+	if !ok {
+		p.t.Fatalf("data source %s is not registered", typeName)
+	}
+	return p.schemaType(schema)
+}
+
+func (p *testProvider) resourceSchema(typeName string) *tfprotov6.Schema {
+	schema, ok := p.schema.ResourceSchemas[typeName]
+	if !ok {
+		p.t.Fatalf("resource %s is not registered", typeName)
+	}
+	return schema
+}
+
+func (p *testProvider) schemaType(schema *tfprotov6.Schema) tftypes.Object {
+	return schema.ValueType().(tftypes.Object)
+}
+
+// object builds a value of typ from attrs, and the attributes not in attrs
+// are null as they are not configured.
+func (p *testProvider) object(typ tftypes.Object, attrs map[string]tftypes.Value) tftypes.Value {
+	p.t.Helper()
+	values := map[string]tftypes.Value{}
+	for name, attrType := range typ.AttributeTypes {
+		values[name] = tftypes.NewValue(attrType, nil)
+	}
+	for name, value := range attrs {
+		if _, ok := values[name]; !ok {
+			p.t.Fatalf("unknown attribute %s", name)
+		}
+		values[name] = value
+	}
+	return tftypes.NewValue(typ, values)
+}
+
+// validate validates the configuration of the resource.
+func (p *testProvider) validate(typeName string, config tftypes.Value) []*tfprotov6.Diagnostic {
+	p.t.Helper()
+	resp, err := p.server.ValidateResourceConfig(context.Background(), &tfprotov6.ValidateResourceConfigRequest{
+		TypeName: typeName,
+		Config:   p.dynamicValue(config),
+	})
+	if err != nil {
+		p.t.Fatalf("ValidateResourceConfig: %v", err)
+	}
+	return resp.Diagnostics
+}
+
+// validateDataSource validates the configuration of the data source.
+func (p *testProvider) validateDataSource(typeName string, config tftypes.Value) []*tfprotov6.Diagnostic {
+	p.t.Helper()
+	resp, err := p.server.ValidateDataResourceConfig(context.Background(), &tfprotov6.ValidateDataResourceConfigRequest{
+		TypeName: typeName,
+		Config:   p.dynamicValue(config),
+	})
+	if err != nil {
+		p.t.Fatalf("ValidateDataResourceConfig: %v", err)
+	}
+	return resp.Diagnostics
+}
+
+// readDataSource reads the data source and returns its state.
+func (p *testProvider) readDataSource(typeName string, config tftypes.Value) tftypes.Value {
+	p.t.Helper()
+	p.checkDiagnostics("ValidateDataResourceConfig", p.validateDataSource(typeName, config))
+	resp, err := p.server.ReadDataSource(context.Background(), &tfprotov6.ReadDataSourceRequest{
+		TypeName: typeName,
+		Config:   p.dynamicValue(config),
+	})
+	if err != nil {
+		p.t.Fatalf("ReadDataSource: %v", err)
+	}
+	p.checkDiagnostics("ReadDataSource", resp.Diagnostics)
+	return p.unmarshal(resp.State, p.dataSourceType(typeName))
+}
+
+// apply plans config against prior and applies the plan like
+// `terraform apply`, and returns the new state and whether the resource has
+// been replaced.
+func (p *testProvider) apply(typeName string, prior, config tftypes.Value) (tftypes.Value, bool) {
+	p.t.Helper()
+	p.checkDiagnostics("ValidateResourceConfig", p.validate(typeName, config))
+	planned, requiresReplace := p.plan(typeName, prior, config)
+	if prior.IsNull() || len(requiresReplace) == 0 {
+		return p.applyChange(typeName, prior, planned, config), false
+	}
+
+	typ := p.resourceType(typeName)
+	p.applyChange(typeName, prior, tftypes.NewValue(typ, nil), tftypes.NewValue(typ, nil))
+	planned, _ = p.plan(typeName, tftypes.NewValue(typ, nil), config)
+	return p.applyChange(typeName, tftypes.NewValue(typ, nil), planned, config), true
+}
+
+// destroy destroys the resource like `terraform destroy`.
+func (p *testProvider) destroy(typeName string, prior tftypes.Value) {
+	p.t.Helper()
+	typ := p.resourceType(typeName)
+	p.applyChange(typeName, prior, tftypes.NewValue(typ, nil), tftypes.NewValue(typ, nil))
+}
+
+// read refreshes the resource, and returns a null value if the resource has
+// been removed from state.
+func (p *testProvider) read(typeName string, state tftypes.Value) (tftypes.Value, []*tfprotov6.Diagnostic) {
+	p.t.Helper()
+	resp, err := p.server.ReadResource(context.Background(), &tfprotov6.ReadResourceRequest{
+		TypeName:     typeName,
+		CurrentState: p.dynamicValue(state),
+	})
+	if err != nil {
+		p.t.Fatalf("ReadResource: %v", err)
+	}
+	p.checkDiagnostics("ReadResource", resp.Diagnostics)
+	return p.unmarshal(resp.NewState, p.resourceType(typeName)), resp.Diagnostics
+}
+
+// importState imports the resource by id and refreshes it like
+// `terraform import`.
+func (p *testProvider) importState(typeName, id string) tftypes.Value {
+	p.t.Helper()
+	resp, err := p.server.ImportResourceState(context.Background(), &tfprotov6.ImportResourceStateRequest{
+		TypeName: typeName,
+		ID:       id,
+	})
+	if err != nil {
+		p.t.Fatalf("ImportResourceState: %v", err)
+	}
+	p.checkDiagnostics("ImportResourceState", resp.Diagnostics)
+	if len(resp.ImportedResources) != 1 {
+		p.t.Fatalf("ImportResourceState: %d resources imported", len(resp.ImportedResources))
+	}
+	state, _ := p.read(typeName, p.unmarshal(resp.ImportedResources[0].State, p.resourceType(typeName)))
+	return state
+}
+
+func (p *testProvider) plan(typeName string, prior, config tftypes.Value) (tftypes.Value, []*tftypes.AttributePath) {
+	p.t.Helper()
+	resp := p.planResponse(typeName, prior, config)
+	p.checkDiagnostics("PlanResourceChange", resp.Diagnostics)
+	return p.unmarshal(resp.PlannedState, p.resourceType(typeName)), resp.RequiresReplace
+}
+
+// planResponse plans config against prior, and returns the response without
+// checking its diagnostics.
+func (p *testProvider) planResponse(typeName string, prior, config tftypes.Value) *tfprotov6.PlanResourceChangeResponse {
+	p.t.Helper()
+	resp, err := p.server.PlanResourceChange(context.Background(), &tfprotov6.PlanResourceChangeRequest{
+		TypeName:         typeName,
+		PriorState:       p.dynamicValue(prior),
+		ProposedNewState: p.dynamicValue(p.proposedNewState(p.resourceSchema(typeName).Block, prior, config)),
+		Config:           p.dynamicValue(config),
+	})
+	if err != nil {
+		p.t.Fatalf("PlanResourceChange: %v", err)
+	}
+	return resp
+}
+
+func (p *testProvider) applyChange(typeName string, prior, planned, config tftypes.Value) tftypes.Value {
+	p.t.Helper()
+	resp, err := p.server.ApplyResourceChange(context.Background(), &tfprotov6.ApplyResourceChangeRequest{
+		TypeName:     typeName,
+		PriorState:   p.dynamicValue(prior),
+		PlannedState: p.dynamicValue(planned),
+		Config:       p.dynamicValue(config),
+	})
+	if err != nil {
+		p.t.Fatalf("ApplyResourceChange: %v", err)
+	}
+	p.checkDiagnostics("ApplyResourceChange", resp.Diagnostics)
+	return p.unmarshal(resp.NewState, p.resourceType(typeName))
+}
+
+// proposedNewState merges prior into config as Terraform does, computed
+// attributes which are not configured keep the values of prior.
+func (p *testProvider) proposedNewState(block *tfprotov6.SchemaBlock, prior, config tftypes.Value) tftypes.Value {
+	if prior.IsNull() || config.IsNull() {
+		return config
+	}
+	var priorAttrs, configAttrs map[string]tftypes.Value
+	if err := prior.As(&priorAttrs); err != nil {
+		p.t.Fatalf("prior state: %v", err)
+	}
+	if err := config.As(&configAttrs); err != nil {
+		p.t.Fatalf("config: %v", err)
+	}
+	for _, attr := range block.Attributes {
+		if attr.Computed && configAttrs[attr.Name].IsNull() {
+			configAttrs[attr.Name] = priorAttrs[attr.Name]
+		}
+	}
+	return tftypes.NewValue(config.Type(), configAttrs)
+}
+
+func (p *testProvider) dynamicValue(value tftypes.Value) *tfprotov6.DynamicValue {
+	p.t.Helper()
+	dynamicValue, err := tfprotov6.NewDynamicValue(value.Type(), value)
+	if err != nil {
+		p.t.Fatalf("NewDynamicValue: %v", err)
+	}
+	return &dynamicValue
+}
+
+func (p *testProvider) unmarshal(value *tfprotov6.DynamicValue, typ tftypes.Type) tftypes.Value {
+	p.t.Helper()
+	if value == nil {
+		return tftypes.NewValue(typ, nil)
+	}
+	v, err := value.Unmarshal(typ)
+	if err != nil {
+		p.t.Fatalf("Unmarshal: %v", err)
+	}
+	return v
+}
+
+func (p *testProvider) checkDiagnostics(rpc string, diagnostics []*tfprotov6.Diagnostic) {
+	p.t.Helper()
+	for _, d := range diagnostics {
+		if d.Severity == tfprotov6.DiagnosticSeverityError {
+			p.t.Fatalf("%s: %s: %s", rpc, d.Summary, d.Detail)
+		}
+	}
+}
+
+// attrValue returns the attribute of the object value.
+func attrValue(t *testing.T, object tftypes.Value, name string) tftypes.Value {
+	t.Helper()
+	var attrs map[string]tftypes.Value
+	if err := object.As(&attrs); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	value, ok := attrs[name]
+	if !ok {
+		t.Fatalf("unknown attribute %s", name)
+	}
+	return value
+}
+
+// attrString returns the string attribute of the object value.
+func attrString(t *testing.T, object tftypes.Value, name string) string {
+	t.Helper()
+	var s string
+	if err := attrValue(t, object, name).As(&s); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return s
+}
+
+// hasError reports whether diagnostics contain an error of summary.
+func hasError(diagnostics []*tfprotov6.Diagnostic, summary string) bool {
+	for _, d := range diagnostics {
+		if d.Severity == tfprotov6.DiagnosticSeverityError && d.Summary == summary {
+			return true
+		}
+	}
+	return false
+}
+
+// writeAPIError writes an error response of Google Cloud APIs.
+func writeAPIError(w http.ResponseWriter, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    code,
+			"message": http.StatusText(code),
+		},
+	})
+}
+
+func TestRegionValidation(t *testing.T) {
+	// the credentials are not allowed to list regions.
+	var lists atomic.Int32
+	compute := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lists.Add(1)
+		writeAPIError(w, http.StatusForbidden)
+	}))
+	t.Cleanup(compute.Close)
+	config := map[string]tftypes.Value{
+		"compute_custom_endpoint": tftypes.NewValue(tftypes.String, compute.URL+"/compute/v1/"),
+	}
+	p := newTestProvider(t, config)
+
+	t.Setenv("GOOGLE_REGION", "us-east1")
+	t.Setenv("GOOGLE_ZONE", "us-east1-b")
+	p.checkDiagnostics("ConfigureProvider with region from environment", p.configure(config))
+	if lists.Load() != 0 {
+		t.Errorf("region from environment variables is validated")
+	}
+
+	config["region"] = tftypes.NewValue(tftypes.String, "us-east1")
+	if diags := p.configure(config); !hasError(diags, "[API ERROR] Failed to list regions to validate region and zone") {
+		t.Errorf("region in the provider block is not validated: %v", diags)
+	}
+}
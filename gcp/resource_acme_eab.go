@@ -1,37 +1,77 @@
 package gcp
 
 import (
-	"bytes"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"regexp"
 	"strings"
 	"time"
 
-	"github.com/cenkalti/backoff/v4"
+	"github.com/hashicorp/terraform-plugin-framework-timeouts/resource/timeouts"
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/mapplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"golang.org/x/net/context"
-	"golang.org/x/oauth2/google"
 )
 
+var (
+	_ resource.Resource                = &acmeEabResource{}
+	_ resource.ResourceWithConfigure   = &acmeEabResource{}
+	_ resource.ResourceWithModifyPlan  = &acmeEabResource{}
+	_ resource.ResourceWithImportState = &acmeEabResource{}
+)
+
+// eabHmacEnvName is the environment variable holding the HMAC of the EAB to
+// be imported if it is not carried by the import ID.
+const eabHmacEnvName = "GOOGLE_PUBLICCA_EAB_HMAC"
+
+// eabNameRegexp matches the resource name of an EAB and captures the project,
+// location and key ID.
+var eabNameRegexp = regexp.MustCompile(`^projects/([^/]+)/locations/([^/]+)/externalAccountKeys/([^/]+)$`)
+
 // acmeEabResource Present st-gcp_acme_eab resource
 type acmeEabResource struct {
 	client *gcpClients
 }
 
+const (
+	publicCAEnvironmentProduction = "production"
+	publicCAEnvironmentStaging    = "staging"
+)
+
 type acmeEabState struct {
-	KeyID      types.String `tfsdk:"key_id"`
-	Name       types.String `tfsdk:"name"`
-	HmacBase64 types.String `tfsdk:"hmac_base64"`
-	CreateAt   types.Int64  `tfsdk:"create_at"` // the unix timestamp of create EAB credential
+	ClientConfig *clientConfig  `tfsdk:"client_config"`
+	Project      types.String   `tfsdk:"project"`
+	Location     types.String   `tfsdk:"location"`
+	Environment  types.String   `tfsdk:"environment"`
+	ApiVersion   types.String   `tfsdk:"api_version"`
+	Keepers      types.Map      `tfsdk:"keepers"`
+	RotationDays types.Int64    `tfsdk:"rotation_days"`
+	KeyID        types.String   `tfsdk:"key_id"`
+	Name         types.String   `tfsdk:"name"`
+	HmacBase64   types.String   `tfsdk:"hmac_base64"`
+	CreateAt     types.Int64    `tfsdk:"create_at"` // the unix timestamp of create EAB credential
+	Timeouts     timeouts.Value `tfsdk:"timeouts"`
 }
 
+const defaultEabCreateTimeout = 5 * time.Minute
+
 type externalAccountKeyResp struct {
 	KeyID     string `json:"keyId"`
 	Name      string `json:"name"`
@@ -49,27 +89,114 @@ func (r *acmeEabResource) Metadata(_ context.Context, req resource.MetadataReque
 }
 
 // Schema
-func (r *acmeEabResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+func (r *acmeEabResource) Schema(ctx context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
 		Description: "Request EAB credential for ACME.",
 		Attributes: map[string]schema.Attribute{
+			"project": &schema.StringAttribute{
+				Description: "The project to create EAB in. Default to the project " +
+					"of client_config, then the project configured in the provider. " +
+					"Changing this forces a new EAB to be created.",
+				Optional: true,
+				Computed: true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+					requiresReplaceIfStateNotNull(),
+				},
+			},
+			"location": &schema.StringAttribute{
+				Description: "The location to create EAB in. Default to global. " +
+					"Changing this forces a new EAB to be created.",
+				Optional: true,
+				Computed: true,
+				Default:  stringdefault.StaticString("global"),
+				PlanModifiers: []planmodifier.String{
+					requiresReplaceIfStateNotNull(),
+				},
+			},
+			"environment": &schema.StringAttribute{
+				Description: "Environment of Google Public CA, either production or " +
+					"staging. Default to production. The staging environment is " +
+					"served by preprod-publicca API unless publicca_custom_endpoint " +
+					"is configured in the provider. Changing this forces a new EAB " +
+					"to be created.",
+				Optional: true,
+				Computed: true,
+				Default:  stringdefault.StaticString(publicCAEnvironmentProduction),
+				Validators: []validator.String{
+					stringvalidator.OneOf(publicCAEnvironmentProduction, publicCAEnvironmentStaging),
+				},
+				PlanModifiers: []planmodifier.String{
+					requiresReplaceIfStateNotNull(),
+				},
+			},
+			"api_version": &schema.StringAttribute{
+				Description: "API version of Google Public CA, either v1 or v1beta1. " +
+					"Default to v1beta1. Changing this forces a new EAB to be created.",
+				Optional: true,
+				Computed: true,
+				Default:  stringdefault.StaticString("v1beta1"),
+				Validators: []validator.String{
+					stringvalidator.OneOf("v1", "v1beta1"),
+				},
+				PlanModifiers: []planmodifier.String{
+					requiresReplaceIfStateNotNull(),
+				},
+			},
+			"keepers": &schema.MapAttribute{
+				Description: "Arbitrary map of values that, when changed, will " +
+					"force a new EAB to be created.",
+				ElementType: types.StringType,
+				Optional:    true,
+				PlanModifiers: []planmodifier.Map{
+					mapplanmodifier.RequiresReplace(),
+				},
+			},
+			"rotation_days": &schema.Int64Attribute{
+				Description: "Number of days after create_at to rotate the EAB. " +
+					"Once it is reached, the EAB is removed from state on refresh " +
+					"and a new EAB will be created. Default to never rotate.",
+				Optional: true,
+				Validators: []validator.Int64{
+					int64validator.AtLeast(1),
+				},
+			},
 			"key_id": &schema.StringAttribute{
 				Description: "EAB key ID.",
 				Computed:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
 			},
 			"name": &schema.StringAttribute{
 				Description: "EAB name.",
 				Computed:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
 			},
 			"hmac_base64": &schema.StringAttribute{
 				Description: "EAB credential with hmac_base64 format.",
 				Computed:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
 			},
 			"create_at": &schema.Int64Attribute{
 				Description: "EAB create timestamp.",
 				Computed:    true,
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.UseStateForUnknown(),
+				},
 			},
 		},
+		Blocks: map[string]schema.Block{
+			"client_config": resourceClientConfigBlock(),
+			"timeouts": timeouts.Block(ctx, timeouts.Opts{
+				Create:            true,
+				CreateDescription: "Time to wait for the EAB to be created, including retries. Default to 5m.",
+			}),
+		},
 	}
 }
 
@@ -98,38 +225,98 @@ func (r *acmeEabResource) Create(ctx context.Context, req resource.CreateRequest
 		return
 	}
 
-	if err := createEabCred(ctx, &state, r.client.credentialsJSON, nil); err != nil {
-		resp.Diagnostics.AddError("createEabCred error", err.Error())
+	createTimeout, d := state.Timeouts.Create(ctx, defaultEabCreateTimeout)
+	resp.Diagnostics.Append(d...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
-	resp.State.Set(ctx, &state)
+	ctx, cancel := context.WithTimeout(ctx, createTimeout)
+	defer cancel()
+
+	clients, d := r.client.clientsFor(ctx, state.ClientConfig)
+	resp.Diagnostics.Append(d...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if err := createEabCred(ctx, &state, clients); err != nil {
+		resp.Diagnostics.AddError("createEabCred error", clients.apiErrorDetail(err))
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
 }
 
-// Read
-func (r *acmeEabResource) Read(_ context.Context, _ resource.ReadRequest, _ *resource.ReadResponse) {
-	// Since GCP does not provide an API to get EAB credential, the Read function will not be implemented.
+// ModifyPlan explains why the EAB will be replaced.
+func (r *acmeEabResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	// Nothing to explain when the resource is being created or destroyed.
+	if req.State.Raw.IsNull() || req.Plan.Raw.IsNull() {
+		return
+	}
+	var state, plan acmeEabState
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if !plan.Keepers.IsUnknown() && !plan.Keepers.Equal(state.Keepers) {
+		resp.Diagnostics.AddAttributeWarning(
+			path.Root("keepers"),
+			"EAB credential will be rotated",
+			fmt.Sprintf("The keepers of EAB %s have been changed, a new EAB will be created.",
+				state.KeyID.ValueString()),
+		)
+	}
 }
 
-// Update
-func (r *acmeEabResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+// Read removes the EAB from state once it is older than rotation_days, so
+// that a new EAB is planned to be created like time_rotating. Deciding the
+// rotation on refresh keeps a saved plan consistent when it is applied after
+// rotation_days is reached. Since GCP does not provide an API to get EAB
+// credential, nothing else is read.
+func (r *acmeEabResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
 	var state acmeEabState
-	d := req.State.Get(ctx, &state)
-	resp.Diagnostics.Append(d...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
 	if resp.Diagnostics.HasError() {
-		tflog.Error(ctx, "Update req.State.Get error")
+		tflog.Error(ctx, "Read req.State.Get error")
+		return
+	}
+	if state.RotationDays.IsNull() {
 		return
 	}
 
-	eabData := externalAccountKeyResp{
-		KeyID:     state.KeyID.String(),
-		Name:      state.Name.String(),
-		B64MacKey: state.HmacBase64.String(),
+	createAt := time.Unix(state.CreateAt.ValueInt64(), 0)
+	rotateAt := createAt.AddDate(0, 0, int(state.RotationDays.ValueInt64()))
+	if time.Now().Before(rotateAt) {
+		return
 	}
-	if err := createEabCred(ctx, &state, r.client.credentialsJSON, &eabData); err != nil {
-		resp.Diagnostics.AddError("createEabCred error", err.Error())
+	resp.Diagnostics.AddWarning(
+		"EAB credential will be rotated",
+		fmt.Sprintf("EAB %s was created at %s, which is more than %d days ago, "+
+			"a new EAB will be created.", state.KeyID.ValueString(),
+			createAt.UTC().Format(time.RFC3339), state.RotationDays.ValueInt64()),
+	)
+	resp.State.RemoveResource(ctx)
+}
+
+// Update only records the changes of attributes which do not require a new
+// EAB, e.g. rotation_days. Every other change replaces the resource, so the
+// API is never called here.
+func (r *acmeEabResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var state, plan acmeEabState
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		tflog.Error(ctx, "Update req.Plan.Get error")
 		return
 	}
-	resp.State.Set(ctx, &state)
+
+	// project is kept null for states created before it was added to the
+	// schema, as UseStateForUnknown plans the null of the state.
+	plan.KeyID = state.KeyID
+	plan.Name = state.Name
+	plan.HmacBase64 = state.HmacBase64
+	plan.CreateAt = state.CreateAt
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 }
 
 // Delete
@@ -140,86 +327,123 @@ func (r *acmeEabResource) Delete(_ context.Context, _ resource.DeleteRequest, re
 	)
 }
 
-const (
-	maxRetryTimes = 3
-	retrySleepMs  = 500
-)
+// ImportState adopts an EAB created outside of Terraform, e.g. by
+// `gcloud publicca external-account-keys create`. The import ID is the EAB
+// name, optionally followed by a comma and the HMAC. Otherwise the HMAC is
+// read from GOOGLE_PUBLICCA_EAB_HMAC environment variable.
+//
+//	projects/<project>/locations/<location>/externalAccountKeys/<key_id>[,<hmac>]
+func (r *acmeEabResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	name, hmac, found := strings.Cut(req.ID, ",")
+	if !found {
+		hmac = os.Getenv(eabHmacEnvName)
+	}
+
+	match := eabNameRegexp.FindStringSubmatch(name)
+	if match == nil {
+		resp.Diagnostics.AddError(
+			"Invalid import ID",
+			fmt.Sprintf("Expected import ID in the format "+
+				"projects/<project>/locations/<location>/externalAccountKeys/<key_id>[,<hmac>], got: %s", name),
+		)
+		return
+	}
+	if hmac == "" {
+		resp.Diagnostics.AddError(
+			"Missing EAB HMAC",
+			"The HMAC of EAB is not recoverable from Google Cloud API. Append it "+
+				"to the import ID after a comma, or set it to the "+eabHmacEnvName+
+				" environment variable.",
+		)
+		return
+	}
+	if _, err := eabHmacKey(hmac); err != nil {
+		resp.Diagnostics.AddError(
+			"Invalid EAB HMAC",
+			"The HMAC of EAB must be encoded in base64url, which is the b64MacKey "+
+				"printed by gcloud.\nAdditional error message: "+err.Error(),
+		)
+		return
+	}
 
-type credentialsGcp struct {
-	Type                    string `json:"type"`
-	ProjectID               string `json:"project_id"`
-	PrivateKeyID            string `json:"private_key_id"`
-	PrivateKey              string `json:"private_key"`
-	ClientEmail             string `json:"client_email"`
-	ClientID                string `json:"client_id"`
-	AuthURI                 string `json:"auth_uri"`
-	TokenURI                string `json:"token_uri"`
-	AuthProviderX509CertURL string `json:"auth_provider_x509_cert_url"`
-	ClientX509CertURL       string `json:"client_x509_cert_url"`
+	// environment and api_version are left null to adopt the values in the
+	// configuration, and create_at is unknown from the API so it is recorded
+	// as the import time.
+	state := acmeEabState{
+		Project:      types.StringValue(match[1]),
+		Location:     types.StringValue(match[2]),
+		Environment:  types.StringNull(),
+		ApiVersion:   types.StringNull(),
+		Keepers:      types.MapNull(types.StringType),
+		RotationDays: types.Int64Null(),
+		KeyID:        types.StringValue(match[3]),
+		Name:         types.StringValue(name),
+		HmacBase64:   types.StringValue(hmac),
+		CreateAt:     types.Int64Value(time.Now().Unix()),
+		Timeouts: timeouts.Value{
+			Object: types.ObjectNull(map[string]attr.Type{
+				"create": types.StringType,
+			}),
+		},
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
+
+// requiresReplaceIfStateNotNull forces replacement when the attribute is
+// changed, except it is added to the schema after the resource was created,
+// so that upgrading the provider will not mint new EAB credentials.
+func requiresReplaceIfStateNotNull() planmodifier.String {
+	return stringplanmodifier.RequiresReplaceIf(
+		func(_ context.Context, req planmodifier.StringRequest, resp *stringplanmodifier.RequiresReplaceIfFuncResponse) {
+			resp.RequiresReplace = !req.StateValue.IsNull()
+		},
+		"Changing this forces a new EAB to be created.",
+		"Changing this forces a new EAB to be created.",
+	)
 }
 
 // createEabCred Create a EAB credential.
 // nolint:lll
 // see: https://cloud.google.com/certificate-manager/docs/reference/public-ca/rest/v1/projects.locations.externalAccountKeys/create
-func createEabCred(ctx context.Context, s *acmeEabState, credentialsJSON []byte, old *externalAccountKeyResp) error {
-	cred := &credentialsGcp{}
-	if err := json.Unmarshal(credentialsJSON, &cred); err != nil {
-		return fmt.Errorf("failed to unmarshal GCP credential JSON: %v", err)
+func createEabCred(ctx context.Context, s *acmeEabState, clients *gcpClients) error {
+	client := clients.httpClient
+
+	project := s.Project.ValueString()
+	if project == "" {
+		project = clients.project
+	}
+	endpoint := clients.publicCAEndpoint
+	if s.Environment.ValueString() == publicCAEnvironmentStaging {
+		endpoint = clients.publicCAStagingEndpoint
 	}
+	var api = fmt.Sprintf(
+		"%s%s/projects/%s/locations/%s/externalAccountKeys",
+		endpoint, s.ApiVersion.ValueString(), project, s.Location.ValueString())
 
-	url := "https://www.googleapis.com/auth/cloud-platform"
-	conf, err := google.JWTConfigFromJSON(credentialsJSON, url)
+	// Only 429 and failures to connect are retried by the client, since a
+	// POST failing otherwise may have created an EAB, see isRetryable.
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, api, nil)
 	if err != nil {
-		return fmt.Errorf("failed to generate JWT config: %v", err)
+		return fmt.Errorf("failed to build request: %v", err)
 	}
-
-	var api = fmt.Sprintf(
-		"https://publicca.googleapis.com/v1beta1/projects/%s/locations/global/externalAccountKeys",
-		cred.ProjectID)
-	var postData *bytes.Reader
-	if old != nil {
-		old.B64MacKey = base64.StdEncoding.Strict().EncodeToString([]byte(old.B64MacKey))
-		buf, _ := json.Marshal(old)
-		postData = bytes.NewReader(buf)
-	}
-
-	var resp *http.Response
-	requestFunc := func() error {
-		if old != nil {
-			resp, err = conf.Client(context.Background()).Post(api, "application/json", postData)
-		} else {
-			resp, err = conf.Client(context.Background()).Post(api, "application/json", nil)
-		}
-
-		if err != nil {
-			errMsg := err.Error()
-			tflog.Warn(ctx, "Failed to request API", map[string]interface{}{
-				"error": errMsg,
-			})
-			if strings.Contains(errMsg, "timeout") ||
-				strings.Contains(errMsg, " 500 ") ||
-				strings.Contains(errMsg, " 504 ") ||
-				strings.Contains(errMsg, "DNS") {
-				return err
-			}
-			return &backoff.PermanentError{Err: err}
-		}
-		return nil
-	}
-	if err := backoff.Retry(requestFunc, backoff.NewExponentialBackOff()); err != nil {
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return fmt.Errorf("failed to read response body: %v", err)
 	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("%w, url: %s, error: %s", errUnauthenticated, api, string(body))
+	}
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("url:" + api + ", error:" + string(body))
+		return fmt.Errorf("url: %s, error: %s", api, string(body))
 	}
 
-	resp.Body.Close()
-
 	var eab externalAccountKeyResp
 	if err = json.Unmarshal(body, &eab); err != nil {
 		return fmt.Errorf("failed to unmarshal EAB response: %v", err)
@@ -230,10 +454,24 @@ func createEabCred(ctx context.Context, s *acmeEabState, credentialsJSON []byte,
 	}
 	eab.B64MacKey = string(eabMacKey)
 
+	s.Project = basetypes.NewStringValue(project)
 	s.KeyID = basetypes.NewStringValue(eab.KeyID)
 	s.Name = basetypes.NewStringValue(eab.Name)
 	s.HmacBase64 = basetypes.NewStringValue(eab.B64MacKey)
 	s.CreateAt = basetypes.NewInt64Value(time.Now().Unix())
 
+	if err := appendEabLedger(clients.eabLedgerPath, eabLedgerEntry{
+		Project:     project,
+		Location:    s.Location.ValueString(),
+		Environment: s.Environment.ValueString(),
+		KeyID:       eab.KeyID,
+		Name:        eab.Name,
+		CreateAt:    s.CreateAt.ValueInt64(),
+	}); err != nil {
+		tflog.Warn(ctx, "Failed to record EAB in ledger", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
 	return nil
 }
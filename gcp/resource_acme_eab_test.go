@@ -0,0 +1,116 @@
+package gcp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+// fakePublicCA serves externalAccountKeys.create of Google Public CA and
+// counts the EABs created. externalAccountKeys.list is answered with
+// listStatus, which is not supported by the API.
+type fakePublicCA struct {
+	*httptest.Server
+	creates    atomic.Int32
+	lists      atomic.Int32
+	listStatus int
+}
+
+func newFakePublicCA(t *testing.T) *fakePublicCA {
+	ca := &fakePublicCA{listStatus: http.StatusNotImplemented}
+	ca.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/externalAccountKeys") {
+			ca.lists.Add(1)
+			writeAPIError(w, ca.listStatus)
+			return
+		}
+		if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/externalAccountKeys") {
+			http.NotFound(w, r)
+			return
+		}
+		keyID := fmt.Sprintf("key-%d", ca.creates.Add(1))
+		parent := r.URL.Path[strings.Index(r.URL.Path, "projects/"):]
+		_ = json.NewEncoder(w).Encode(externalAccountKeyResp{
+			KeyID: keyID,
+			Name:  parent + "/" + keyID,
+			// b64MacKey is the base64url HMAC encoded in base64 again.
+			B64MacKey: base64.StdEncoding.EncodeToString(
+				[]byte(base64.RawURLEncoding.EncodeToString([]byte("hmac-" + keyID)))),
+		})
+	}))
+	t.Cleanup(ca.Close)
+	return ca
+}
+
+func TestAcmeEabUpdateNeverCreatesEab(t *testing.T) {
+	const typeName = "st-gcp_acme_eab"
+	ca := newFakePublicCA(t)
+	p := newTestProvider(t, map[string]tftypes.Value{
+		"publicca_custom_endpoint": tftypes.NewValue(tftypes.String, ca.URL),
+	})
+	typ := p.resourceType(typeName)
+	clientConfigType := typ.AttributeTypes["client_config"].(tftypes.Object)
+	keepersType := typ.AttributeTypes["keepers"]
+
+	state, _ := p.apply(typeName, tftypes.NewValue(typ, nil), p.object(typ, nil))
+	if got := ca.creates.Load(); got != 1 {
+		t.Fatalf("create: %d EABs created, want 1", got)
+	}
+
+	steps := []struct {
+		name     string
+		attrs    map[string]tftypes.Value
+		replaced bool
+		creates  int32
+	}{
+		{
+			name: "rotation_days",
+			attrs: map[string]tftypes.Value{
+				"rotation_days": tftypes.NewValue(tftypes.Number, 30),
+			},
+			creates: 1,
+		},
+		{
+			name: "client_config",
+			attrs: map[string]tftypes.Value{
+				"rotation_days": tftypes.NewValue(tftypes.Number, 30),
+				"client_config": p.object(clientConfigType, map[string]tftypes.Value{
+					"access_token": tftypes.NewValue(tftypes.String, "other-token"),
+				}),
+			},
+			creates: 1,
+		},
+		{
+			name: "keepers",
+			attrs: map[string]tftypes.Value{
+				"rotation_days": tftypes.NewValue(tftypes.Number, 30),
+				"keepers": tftypes.NewValue(keepersType, map[string]tftypes.Value{
+					"account": tftypes.NewValue(tftypes.String, "acme@example.com"),
+				}),
+			},
+			replaced: true,
+			creates:  2,
+		},
+	}
+	for _, step := range steps {
+		keyID := attrString(t, state, "key_id")
+		var replaced bool
+		state, replaced = p.apply(typeName, state, p.object(typ, step.attrs))
+		if replaced != step.replaced {
+			t.Errorf("%s: replaced = %v, want %v", step.name, replaced, step.replaced)
+		}
+		if got := ca.creates.Load(); got != step.creates {
+			t.Errorf("%s: %d EABs created, want %d", step.name, got, step.creates)
+		}
+		if !step.replaced && attrString(t, state, "key_id") != keyID {
+			t.Errorf("%s: key_id changed from %s to %s", step.name, keyID, attrString(t, state, "key_id"))
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package gcp
+
+import (
+	"context"
+	"crypto"
+	"errors"
+
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"golang.org/x/crypto/acme"
+)
+
+var (
+	_ resource.Resource              = &acmeRegistrationResource{}
+	_ resource.ResourceWithConfigure = &acmeRegistrationResource{}
+)
+
+// acmeRegistrationResource Present st-gcp_acme_registration resource
+type acmeRegistrationResource struct {
+	client *gcpClients
+}
+
+type acmeRegistrationState struct {
+	ClientConfig  *clientConfig `tfsdk:"client_config"`
+	Project       types.String  `tfsdk:"project"`
+	Location      types.String  `tfsdk:"location"`
+	Environment   types.String  `tfsdk:"environment"`
+	ApiVersion    types.String  `tfsdk:"api_version"`
+	DirectoryURL  types.String  `tfsdk:"directory_url"`
+	EmailAddress  types.String  `tfsdk:"email_address"`
+	AccountKeyPem types.String  `tfsdk:"account_key_pem"`
+	AccountURL    types.String  `tfsdk:"account_url"`
+	EabKeyID      types.String  `tfsdk:"eab_key_id"`
+}
+
+// NewAcmeRegistrationResource
+func NewAcmeRegistrationResource() resource.Resource {
+	return &acmeRegistrationResource{}
+}
+
+// Metadata
+func (r *acmeRegistrationResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_acme_registration"
+}
+
+// Schema
+func (r *acmeRegistrationResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Register an ACME account on Google Public CA, which is bound " +
+			"to a new EAB credential.",
+		Attributes: map[string]schema.Attribute{
+			"project": &schema.StringAttribute{
+				Description: "The project to create EAB in. Default to the project " +
+					"of client_config, then the project configured in the provider.",
+				Optional: true,
+				Computed: true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"location": &schema.StringAttribute{
+				Description: "The location to create EAB in. Default to global.",
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("global"),
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"environment": &schema.StringAttribute{
+				Description: "Environment of Google Public CA, either production or " +
+					"staging. Default to production.",
+				Optional: true,
+				Computed: true,
+				Default:  stringdefault.StaticString(publicCAEnvironmentProduction),
+				Validators: []validator.String{
+					stringvalidator.OneOf(publicCAEnvironmentProduction, publicCAEnvironmentStaging),
+				},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"api_version": &schema.StringAttribute{
+				Description: "API version of Google Public CA to create EAB with, " +
+					"either v1 or v1beta1. Default to v1beta1.",
+				Optional: true,
+				Computed: true,
+				Default:  stringdefault.StaticString("v1beta1"),
+				Validators: []validator.String{
+					stringvalidator.OneOf("v1", "v1beta1"),
+				},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"directory_url": &schema.StringAttribute{
+				Description: "The ACME directory URL. Default to the directory of " +
+					"Google Public CA in the environment, it can be set to another " +
+					"ACME server for testing, e.g. Pebble.",
+				Optional: true,
+				Computed: true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"email_address": &schema.StringAttribute{
+				Description: "The contact email address of the account.",
+				Optional:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"account_key_pem": &schema.StringAttribute{
+				Description: "The private key of the account in PEM format, either " +
+					"RSA or ECDSA. An ECDSA P-256 key is generated if it is not set.",
+				Optional:  true,
+				Computed:  true,
+				Sensitive: true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"account_url": &schema.StringAttribute{
+				Description: "The URL of the registered account, which is the key ID " +
+					"of the account in ACME requests.",
+				Computed: true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"eab_key_id": &schema.StringAttribute{
+				Description: "The key ID of the EAB the account is bound to.",
+				Computed:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+		},
+		Blocks: map[string]schema.Block{
+			"client_config": resourceClientConfigBlock(),
+		},
+	}
+}
+
+// Configure
+func (r *acmeRegistrationResource) Configure(_ context.Context,
+	req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	client, ok := req.ProviderData.(*gcpClients)
+	if !ok {
+		resp.Diagnostics.AddError("req.ProviderData not a gcpClients error", "")
+		return
+	}
+	r.client = client
+}
+
+// Create registers an account with newAccount request of RFC 8555, which
+// carries the EAB as JWS signed by the HMAC.
+// see: https://www.rfc-editor.org/rfc/rfc8555#section-7.3.4
+func (r *acmeRegistrationResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var plan acmeRegistrationState
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		tflog.Error(ctx, "Create req.Plan.Get error")
+		return
+	}
+
+	var key crypto.Signer
+	var err error
+	if plan.AccountKeyPem.IsUnknown() || plan.AccountKeyPem.IsNull() {
+		var keyPem string
+		key, keyPem, err = generateAccountKey()
+		plan.AccountKeyPem = types.StringValue(keyPem)
+	} else {
+		key, err = parsePrivateKey(plan.AccountKeyPem.ValueString())
+	}
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("account_key_pem"),
+			"Invalid ACME account key",
+			err.Error(),
+		)
+		return
+	}
+	if plan.DirectoryURL.IsUnknown() || plan.DirectoryURL.IsNull() {
+		plan.DirectoryURL = types.StringValue(publicCADirectoryURL(plan.Environment.ValueString()))
+	}
+
+	eab := acmeEabState{
+		Project:     plan.Project,
+		Location:    plan.Location,
+		Environment: plan.Environment,
+		ApiVersion:  plan.ApiVersion,
+	}
+	clients, diags := r.client.clientsFor(ctx, plan.ClientConfig)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if err := createEabCred(ctx, &eab, clients); err != nil {
+		resp.Diagnostics.AddError("createEabCred error", clients.apiErrorDetail(err))
+		return
+	}
+	plan.Project = eab.Project
+	plan.EabKeyID = eab.KeyID
+
+	hmacKey, err := eabHmacKey(eab.HmacBase64.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid EAB HMAC", err.Error())
+		return
+	}
+	account := &acme.Account{
+		ExternalAccountBinding: &acme.ExternalAccountBinding{
+			KID: eab.KeyID.ValueString(),
+			Key: hmacKey,
+		},
+	}
+	if email := plan.EmailAddress.ValueString(); email != "" {
+		account.Contact = []string{"mailto:" + email}
+	}
+
+	client := r.client.newAcmeClient(key, plan.DirectoryURL.ValueString(), "")
+	account, err = client.Register(ctx, account, acme.AcceptTOS)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"[API ERROR] Failed to register ACME account",
+			err.Error(),
+		)
+		return
+	}
+	plan.AccountURL = types.StringValue(account.URI)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+// Read removes the account from state if it has been deactivated.
+func (r *acmeRegistrationResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var state acmeRegistrationState
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		tflog.Error(ctx, "Read req.State.Get error")
+		return
+	}
+
+	key, err := parsePrivateKey(state.AccountKeyPem.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid ACME account key", err.Error())
+		return
+	}
+	client := r.client.newAcmeClient(key, state.DirectoryURL.ValueString(), state.AccountURL.ValueString())
+	account, err := client.GetReg(ctx, "")
+	if errors.Is(err, acme.ErrNoAccount) {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"[API ERROR] Failed to get ACME account",
+			err.Error(),
+		)
+		return
+	}
+	if account.Status == acme.StatusDeactivated || account.Status == acme.StatusRevoked {
+		resp.State.RemoveResource(ctx)
+	}
+}
+
+// Update does nothing as every attribute forces a new account.
+func (r *acmeRegistrationResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan acmeRegistrationState
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+// Delete deactivates the account.
+func (r *acmeRegistrationResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var state acmeRegistrationState
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		tflog.Error(ctx, "Delete req.State.Get error")
+		return
+	}
+
+	key, err := parsePrivateKey(state.AccountKeyPem.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid ACME account key", err.Error())
+		return
+	}
+	client := r.client.newAcmeClient(key, state.DirectoryURL.ValueString(), state.AccountURL.ValueString())
+	if err := client.DeactivateReg(ctx); err != nil && !errors.Is(err, acme.ErrNoAccount) {
+		resp.Diagnostics.AddError(
+			"[API ERROR] Failed to deactivate ACME account",
+			err.Error(),
+		)
+	}
+}
@@ -0,0 +1,518 @@
+package gcp
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework-validators/mapvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/myklst/terraform-provider-st-gcp/internal/tagcodec"
+	googleComputeClient "google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+)
+
+var (
+	_ resource.Resource                = &lbBackendServiceTagsResource{}
+	_ resource.ResourceWithConfigure   = &lbBackendServiceTagsResource{}
+	_ resource.ResourceWithImportState = &lbBackendServiceTagsResource{}
+	_ resource.ResourceWithModifyPlan  = &lbBackendServiceTagsResource{}
+)
+
+// backendServiceNameRegexp matches the ID of the resource, which is the
+// relative resource name of a global or regional backend service, and
+// captures the project, region and name.
+var backendServiceNameRegexp = regexp.MustCompile(`^projects/([^/]+)/(?:global|regions/([^/]+))/backendServices/([^/]+)$`)
+
+const (
+	// maxFingerprintRetries is the number of times the description is patched
+	// again after it is changed by others between get and patch.
+	maxFingerprintRetries = 5
+	// computeOperationPollInterval is the interval to get an operation until
+	// it is done. Operations are polled instead of waited by the wait method,
+	// which blocks for up to 2 minutes and exceeds request_timeout.
+	computeOperationPollInterval = 2 * time.Second
+)
+
+// lbBackendServiceTagsResource Present st-gcp_load_balancer_backend_service_tags resource
+type lbBackendServiceTagsResource struct {
+	client *gcpClients
+}
+
+type lbBackendServiceTagsState struct {
+	ClientConfig *clientConfig `tfsdk:"client_config"`
+	ID           types.String  `tfsdk:"id"`
+	Project      types.String  `tfsdk:"project"`
+	Region       types.String  `tfsdk:"region"`
+	Name         types.String  `tfsdk:"name"`
+	TagFormat    types.String  `tfsdk:"tag_format"`
+	Tags         types.Map     `tfsdk:"tags"`
+}
+
+// backendServiceRef refers to a global backend service if region is empty,
+// otherwise a regional one.
+type backendServiceRef struct {
+	project string
+	region  string
+	name    string
+}
+
+func (b backendServiceRef) id() string {
+	if b.region == "" {
+		return fmt.Sprintf("projects/%s/global/backendServices/%s", b.project, b.name)
+	}
+	return fmt.Sprintf("projects/%s/regions/%s/backendServices/%s", b.project, b.region, b.name)
+}
+
+// NewLbBackendServiceTagsResource
+func NewLbBackendServiceTagsResource() resource.Resource {
+	return &lbBackendServiceTagsResource{}
+}
+
+// Metadata
+func (r *lbBackendServiceTagsResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_load_balancer_backend_service_tags"
+}
+
+// Schema
+func (r *lbBackendServiceTagsResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Manage tags in the description of an existing load balancer " +
+			"backend service. Text in the description which is not tags is kept.",
+		Attributes: map[string]schema.Attribute{
+			"id": &schema.StringAttribute{
+				Description: "The relative resource name of the backend service.",
+				Computed:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"project": &schema.StringAttribute{
+				Description: "The project of the backend service. Default to the " +
+					"project of client_config, then the project configured in the provider.",
+				Optional: true,
+				Computed: true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"region": &schema.StringAttribute{
+				Description: "The region of the backend service, or global for a " +
+					"global backend service. Default to the region configured in " +
+					"the provider, then global. The region is kept once the " +
+					"resource is created, even if the region of the provider changes.",
+				Optional: true,
+				Computed: true,
+				Validators: []validator.String{
+					stringvalidator.LengthAtLeast(1),
+				},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"name": &schema.StringAttribute{
+				Description: "The name of the backend service.",
+				Required:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"tag_format": &schema.StringAttribute{
+				Description: "Format of tags in the description, one of pipe_colon " +
+					"(key:value|key:value), kv_semicolon (key=value;key=value), json " +
+					"({\"key\":\"value\"}) or auto, which keeps the format detected " +
+					"from the description. json requires the description to be empty or " +
+					"a JSON object, since free text can only be kept as members of the " +
+					"JSON object. Default to tag_format configured in the provider.",
+				Optional: true,
+				Validators: []validator.String{
+					stringvalidator.OneOf(tagcodec.Formats()...),
+				},
+			},
+			"tags": &schema.MapAttribute{
+				Description: "Tags of the backend service. Tags added outside of " +
+					"Terraform are removed.",
+				ElementType: types.StringType,
+				Required:    true,
+				Validators: []validator.Map{
+					mapvalidator.KeysAre(stringvalidator.LengthAtLeast(1)),
+				},
+			},
+		},
+		Blocks: map[string]schema.Block{
+			"client_config": resourceClientConfigBlock(),
+		},
+	}
+}
+
+// Configure
+func (r *lbBackendServiceTagsResource) Configure(_ context.Context,
+	req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	client, ok := req.ProviderData.(*gcpClients)
+	if !ok {
+		resp.Diagnostics.AddError("req.ProviderData not a gcpClients error", "")
+		return
+	}
+	r.client = client
+}
+
+// Create
+func (r *lbBackendServiceTagsResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var plan lbBackendServiceTagsState
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		tflog.Error(ctx, "Create req.Plan.Get error")
+		return
+	}
+	r.writeTags(ctx, &plan, &resp.State, &resp.Diagnostics)
+}
+
+// Read sets tags decoded from the description, so that tags changed outside
+// of Terraform are detected as drift.
+func (r *lbBackendServiceTagsResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var state lbBackendServiceTagsState
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		tflog.Error(ctx, "Read req.State.Get error")
+		return
+	}
+
+	clients, diags := r.client.clientsFor(ctx, state.ClientConfig)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	ref := stateBackendServiceRef(&state, clients)
+	backendService, err := getBackendService(ctx, clients, ref)
+	if isNotFound(err) {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"[API ERROR] Failed to get backend service",
+			clients.apiErrorDetail(err),
+		)
+		return
+	}
+
+	tags, _, _, err := tagcodec.Split(backendService.Description, r.tagFormat(clients, &state))
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Failed to decode tags of backend service",
+			fmt.Sprintf("Description of %s: %v", ref.id(), err),
+		)
+		return
+	}
+	tagsValue, diags := types.MapValueFrom(ctx, types.StringType, tags)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	state.ID = types.StringValue(ref.id())
+	state.Project = types.StringValue(ref.project)
+	state.Tags = tagsValue
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
+
+// Update
+func (r *lbBackendServiceTagsResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan lbBackendServiceTagsState
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		tflog.Error(ctx, "Update req.Plan.Get error")
+		return
+	}
+	r.writeTags(ctx, &plan, &resp.State, &resp.Diagnostics)
+}
+
+// ModifyPlan rejects json tag_format if the free text in the description can
+// not be kept in a JSON object, which would fail on apply. The backend service
+// is read only when json is used and the configuration is known.
+func (r *lbBackendServiceTagsResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() || !req.Config.Raw.IsFullyKnown() || r.client == nil {
+		return
+	}
+	var plan lbBackendServiceTagsState
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	clients, diags := r.client.clientsFor(ctx, plan.ClientConfig)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	format := r.tagFormat(clients, &plan)
+	if format != tagcodec.FormatJSON {
+		return
+	}
+	tags := map[string]string{}
+	resp.Diagnostics.Append(plan.Tags.ElementsAs(ctx, &tags, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if plan.Region.IsUnknown() {
+		plan.Region = types.StringValue(cmp.Or(clients.region, backendServiceScopeGlobal))
+	}
+
+	// Failures to read the backend service are left to apply.
+	ref := stateBackendServiceRef(&plan, clients)
+	backendService, err := getBackendService(ctx, clients, ref)
+	if err != nil {
+		return
+	}
+	current, freeText, resolved, err := tagcodec.Split(backendService.Description, format)
+	if err != nil || maps.Equal(current, tags) {
+		return
+	}
+	if _, err := tagcodec.Join(freeText, tags, resolved); errors.Is(err, tagcodec.ErrFreeTextNotJSON) {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("tag_format"),
+			"Description can not be kept in json tag_format",
+			freeTextNotJSONDetail(ref, err),
+		)
+	}
+}
+
+// freeTextNotJSONDetail explains how to write tags in json into a description
+// containing free text.
+func freeTextNotJSONDetail(ref backendServiceRef, err error) string {
+	return fmt.Sprintf("Description of %s: %v\n\n"+
+		"json tag_format requires the description to be empty or a JSON object. "+
+		"Rewrite the free text as members of a JSON object, e.g. "+
+		"{\"note\":\"managed by hand\"}, remove it from the description, or use "+
+		"another tag_format, e.g. auto, which keeps the format of the description.",
+		ref.id(), err)
+}
+
+// Delete removes all tags from the description and keeps the free text. The
+// backend service itself is never deleted.
+func (r *lbBackendServiceTagsResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var state lbBackendServiceTagsState
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		tflog.Error(ctx, "Delete req.State.Get error")
+		return
+	}
+
+	clients, diags := r.client.clientsFor(ctx, state.ClientConfig)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	ref := stateBackendServiceRef(&state, clients)
+	err := patchBackendServiceTags(ctx, clients, ref, nil, r.tagFormat(clients, &state))
+	if err != nil && !isNotFound(err) {
+		resp.Diagnostics.AddError(
+			"[API ERROR] Failed to remove tags of backend service",
+			clients.apiErrorDetail(err),
+		)
+	}
+}
+
+// ImportState adopts the tags of an existing backend service, the import ID
+// is the relative resource name of the backend service.
+//
+//	projects/<project>/global/backendServices/<name>
+//	projects/<project>/regions/<region>/backendServices/<name>
+func (r *lbBackendServiceTagsResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	match := backendServiceNameRegexp.FindStringSubmatch(req.ID)
+	if match == nil {
+		resp.Diagnostics.AddError(
+			"Invalid import ID",
+			fmt.Sprintf("Expected import ID in the format "+
+				"projects/<project>/global/backendServices/<name> or "+
+				"projects/<project>/regions/<region>/backendServices/<name>, got: %s", req.ID),
+		)
+		return
+	}
+
+	region := types.StringValue(backendServiceScopeGlobal)
+	if match[2] != "" {
+		region = types.StringValue(match[2])
+	}
+	state := lbBackendServiceTagsState{
+		ID:        types.StringValue(req.ID),
+		Project:   types.StringValue(match[1]),
+		Region:    region,
+		Name:      types.StringValue(match[3]),
+		TagFormat: types.StringNull(),
+		Tags:      types.MapNull(types.StringType),
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
+
+// writeTags patches the tags of plan into the description, and sets plan to
+// the state if it succeeds.
+func (r *lbBackendServiceTagsResource) writeTags(ctx context.Context,
+	plan *lbBackendServiceTagsState, state *tfsdk.State, diagnostics *diag.Diagnostics) {
+	clients, diags := r.client.clientsFor(ctx, plan.ClientConfig)
+	diagnostics.Append(diags...)
+	if diagnostics.HasError() {
+		return
+	}
+
+	tags := map[string]string{}
+	diagnostics.Append(plan.Tags.ElementsAs(ctx, &tags, false)...)
+	if diagnostics.HasError() {
+		return
+	}
+	if plan.Region.IsUnknown() {
+		plan.Region = types.StringValue(cmp.Or(clients.region, backendServiceScopeGlobal))
+	}
+	ref := stateBackendServiceRef(plan, clients)
+	err := patchBackendServiceTags(ctx, clients, ref, tags, r.tagFormat(clients, plan))
+	if errors.Is(err, tagcodec.ErrFreeTextNotJSON) {
+		diagnostics.AddAttributeError(
+			path.Root("tag_format"),
+			"Description can not be kept in json tag_format",
+			freeTextNotJSONDetail(ref, err),
+		)
+		return
+	}
+	if err != nil {
+		diagnostics.AddError(
+			"[API ERROR] Failed to write tags of backend service",
+			clients.apiErrorDetail(err),
+		)
+		return
+	}
+
+	plan.ID = types.StringValue(ref.id())
+	plan.Project = types.StringValue(ref.project)
+	diagnostics.Append(state.Set(ctx, plan)...)
+}
+
+func (r *lbBackendServiceTagsResource) tagFormat(clients *gcpClients, state *lbBackendServiceTagsState) tagcodec.Format {
+	if state.TagFormat.IsNull() || state.TagFormat.IsUnknown() {
+		return clients.tagFormat
+	}
+	return tagcodec.Format(state.TagFormat.ValueString())
+}
+
+// stateBackendServiceRef returns the backend service of the resource. region
+// of the resource is "global" for a global backend service.
+func stateBackendServiceRef(state *lbBackendServiceTagsState, clients *gcpClients) backendServiceRef {
+	ref := backendServiceRef{
+		project: clients.project,
+		region:  state.Region.ValueString(),
+		name:    state.Name.ValueString(),
+	}
+	if ref.region == backendServiceScopeGlobal {
+		ref.region = ""
+	}
+	if !state.Project.IsNull() && !state.Project.IsUnknown() {
+		ref.project = state.Project.ValueString()
+	}
+	return ref
+}
+
+func getBackendService(ctx context.Context, clients *gcpClients, ref backendServiceRef) (*googleComputeClient.BackendService, error) {
+	if ref.region == "" {
+		return clients.computeClient.BackendServices.Get(ref.project, ref.name).Context(ctx).Do()
+	}
+	return clients.computeClient.RegionBackendServices.Get(ref.project, ref.region, ref.name).Context(ctx).Do()
+}
+
+// patchBackendServiceTags replaces the tags in the description of the backend
+// service and keeps the free text. The description is patched with the
+// fingerprint of the backend service, and it is read and patched again if the
+// backend service has been changed by others in between.
+func patchBackendServiceTags(ctx context.Context, clients *gcpClients,
+	ref backendServiceRef, tags map[string]string, format tagcodec.Format) error {
+	for attempt := 0; ; attempt++ {
+		backendService, err := getBackendService(ctx, clients, ref)
+		if err != nil {
+			return err
+		}
+		current, freeText, resolved, err := tagcodec.Split(backendService.Description, format)
+		if err != nil {
+			return fmt.Errorf("failed to decode tags in description of %s: %v", ref.id(), err)
+		}
+		if maps.Equal(current, tags) {
+			return nil
+		}
+		description, err := tagcodec.Join(freeText, tags, resolved)
+		if err != nil {
+			return err
+		}
+		if description == backendService.Description {
+			return nil
+		}
+
+		patch := &googleComputeClient.BackendService{
+			Description:     description,
+			Fingerprint:     backendService.Fingerprint,
+			ForceSendFields: []string{"Description"},
+		}
+		err = patchBackendService(ctx, clients, ref, patch)
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed &&
+			attempt < maxFingerprintRetries {
+			tflog.Debug(ctx, "fingerprint of backend service is changed, retrying", map[string]interface{}{
+				"backend_service": ref.id(),
+				"attempt":         attempt + 1,
+			})
+			continue
+		}
+		return err
+	}
+}
+
+// patchBackendService patches the backend service and polls the operation
+// until it is done.
+func patchBackendService(ctx context.Context, clients *gcpClients,
+	ref backendServiceRef, patch *googleComputeClient.BackendService) error {
+	var op *googleComputeClient.Operation
+	var err error
+	if ref.region == "" {
+		op, err = clients.computeClient.BackendServices.Patch(ref.project, ref.name, patch).Context(ctx).Do()
+	} else {
+		op, err = clients.computeClient.RegionBackendServices.Patch(ref.project, ref.region, ref.name, patch).Context(ctx).Do()
+	}
+	for err == nil && op.Status != "DONE" {
+		tflog.Debug(ctx, "Waiting for backend service operation", map[string]interface{}{
+			"operation": op.Name,
+			"status":    op.Status,
+		})
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(computeOperationPollInterval):
+		}
+		if ref.region == "" {
+			op, err = clients.computeClient.GlobalOperations.Get(ref.project, op.Name).Context(ctx).Do()
+		} else {
+			op, err = clients.computeClient.RegionOperations.Get(ref.project, ref.region, op.Name).Context(ctx).Do()
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if op.Error != nil && len(op.Error.Errors) > 0 {
+		return fmt.Errorf("operation %s failed: %s", op.Name, op.Error.Errors[0].Message)
+	}
+	return nil
+}
@@ -0,0 +1,380 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/myklst/terraform-provider-st-gcp/internal/tagcodec"
+	googleComputeClient "google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// fakeComputeBackendServices serves list, get and patch of global and regional
+// backend services by the Compute Engine API, and rejects the access tokens in
+// expired with 401 as Google does for expired tokens. Backend services are
+// keyed by their path in the project, e.g. global/backendServices/web or
+// regions/us-east1/backendServices/web.
+type fakeComputeBackendServices struct {
+	*httptest.Server
+	mu              sync.Mutex
+	backendServices map[string]*googleComputeClient.BackendService
+	expired         map[string]bool
+	// tokens are the access tokens of the requests in order.
+	tokens []string
+	// paths are the paths in the project of the requests in order.
+	paths []string
+	// runningOperations makes operations of patches RUNNING until they are
+	// got once.
+	runningOperations bool
+	// concurrentWrites are descriptions written by others between get and
+	// patch, one for each patch in order, which fail with 412.
+	concurrentWrites []string
+}
+
+func newFakeComputeBackendServices(t *testing.T,
+	backendServices map[string]*googleComputeClient.BackendService) *fakeComputeBackendServices {
+	compute := &fakeComputeBackendServices{
+		backendServices: backendServices,
+		expired:         map[string]bool{},
+	}
+	compute.Server = httptest.NewServer(http.HandlerFunc(compute.serveHTTP))
+	t.Cleanup(compute.Close)
+	return compute
+}
+
+func (f *fakeComputeBackendServices) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	f.tokens = append(f.tokens, token)
+	if f.expired[token] {
+		writeAPIError(w, http.StatusUnauthorized)
+		return
+	}
+
+	// .../projects/{project}/{path}
+	_, key, _ := strings.Cut(r.URL.Path, "/projects/")
+	_, key, _ = strings.Cut(key, "/")
+	f.paths = append(f.paths, key)
+	if r.Method == http.MethodGet && strings.Contains(key, "/operations/") {
+		_ = json.NewEncoder(w).Encode(&googleComputeClient.Operation{
+			Name:   key[strings.LastIndex(key, "/")+1:],
+			Status: "DONE",
+		})
+		return
+	}
+	if r.Method == http.MethodGet && strings.HasSuffix(key, "/backendServices") {
+		list := &googleComputeClient.BackendServiceList{}
+		for _, name := range slices.Sorted(maps.Keys(f.backendServices)) {
+			if strings.HasPrefix(name, key+"/") {
+				list.Items = append(list.Items, f.backendServices[name])
+			}
+		}
+		_ = json.NewEncoder(w).Encode(list)
+		return
+	}
+	backendService, ok := f.backendServices[key]
+	if !ok {
+		writeAPIError(w, http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		_ = json.NewEncoder(w).Encode(backendService)
+	case http.MethodPatch:
+		var patch googleComputeClient.BackendService
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			writeAPIError(w, http.StatusBadRequest)
+			return
+		}
+		if len(f.concurrentWrites) > 0 {
+			backendService.Description = f.concurrentWrites[0]
+			backendService.Fingerprint += "1"
+			f.concurrentWrites = f.concurrentWrites[1:]
+		}
+		if patch.Fingerprint != backendService.Fingerprint {
+			writeAPIError(w, http.StatusPreconditionFailed)
+			return
+		}
+		backendService.Description = patch.Description
+		backendService.Fingerprint += "1"
+		status := "DONE"
+		if f.runningOperations {
+			status = "RUNNING"
+		}
+		_ = json.NewEncoder(w).Encode(&googleComputeClient.Operation{Name: "operation-1", Status: status})
+	default:
+		writeAPIError(w, http.StatusMethodNotAllowed)
+	}
+}
+
+// description returns the description of the backend service at path.
+func (f *fakeComputeBackendServices) description(path string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.backendServices[path].Description
+}
+
+// newTestComputeClients returns clients of the Compute Engine API served by
+// the fake.
+func newTestComputeClients(t *testing.T, compute *fakeComputeBackendServices) *gcpClients {
+	computeClient, err := googleComputeClient.NewService(context.Background(),
+		option.WithHTTPClient(compute.Client()), option.WithEndpoint(compute.URL+"/compute/v1/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &gcpClients{computeClient: computeClient}
+}
+
+// popPaths returns the paths of the requests since the last call.
+func (f *fakeComputeBackendServices) popPaths() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	paths := f.paths
+	f.paths = nil
+	return paths
+}
+
+// popTokens returns the access tokens of the requests since the last call.
+func (f *fakeComputeBackendServices) popTokens() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tokens := f.tokens
+	f.tokens = nil
+	return tokens
+}
+
+func TestLbBackendServiceTagsClientConfig(t *testing.T) {
+	const typeName = "st-gcp_load_balancer_backend_service_tags"
+	compute := newFakeComputeBackendServices(t, map[string]*googleComputeClient.BackendService{
+		"global/backendServices/web": {Name: "web", Description: "managed by hand", Fingerprint: "f"},
+	})
+	// the provider identity can not access the backend service, only the one
+	// of client_config can.
+	compute.expired["test-token"] = true
+	p := newTestProvider(t, map[string]tftypes.Value{
+		"compute_custom_endpoint": tftypes.NewValue(tftypes.String, compute.URL+"/compute/v1/"),
+	})
+	typ := p.resourceType(typeName)
+	clientConfigType := typ.AttributeTypes["client_config"].(tftypes.Object)
+	config := p.object(typ, map[string]tftypes.Value{
+		"name": tftypes.NewValue(tftypes.String, "web"),
+		"tags": tftypes.NewValue(typ.AttributeTypes["tags"], map[string]tftypes.Value{
+			"team": tftypes.NewValue(tftypes.String, "web"),
+		}),
+		"client_config": p.object(clientConfigType, map[string]tftypes.Value{
+			"access_token": tftypes.NewValue(tftypes.String, "override-token"),
+		}),
+	})
+
+	state, _ := p.apply(typeName, tftypes.NewValue(typ, nil), config)
+	state, _ = p.read(typeName, state)
+	if tags := attrValue(t, state, "tags"); !tags.Equal(attrValue(t, config, "tags")) {
+		t.Errorf("read: tags = %v", tags)
+	}
+	p.destroy(typeName, state)
+	for _, token := range compute.popTokens() {
+		if token != "override-token" {
+			t.Errorf("request with access token %q, want override-token of client_config", token)
+		}
+	}
+	if description := compute.description("global/backendServices/web"); description != "managed by hand" {
+		t.Errorf("destroy: description = %q, want the free text kept", description)
+	}
+}
+
+// TestLbBackendServiceTagsJSONFreeText checks that json tag_format is rejected
+// on plan if the free text in the description can not be kept.
+func TestLbBackendServiceTagsJSONFreeText(t *testing.T) {
+	const typeName = "st-gcp_load_balancer_backend_service_tags"
+	tests := []struct {
+		description string
+		// want is the description after apply, or empty if the plan fails.
+		want string
+	}{
+		{"managed by hand", ""},
+		{"", `{"team":"web"}`},
+		{`{"note":null}`, `{"note":null,"team":"web"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			compute := newFakeComputeBackendServices(t, map[string]*googleComputeClient.BackendService{
+				"global/backendServices/web": {Name: "web", Description: tt.description, Fingerprint: "f"},
+			})
+			p := newTestProvider(t, map[string]tftypes.Value{
+				"compute_custom_endpoint": tftypes.NewValue(tftypes.String, compute.URL+"/compute/v1/"),
+			})
+			typ := p.resourceType(typeName)
+			config := p.object(typ, map[string]tftypes.Value{
+				"name":       tftypes.NewValue(tftypes.String, "web"),
+				"tag_format": tftypes.NewValue(tftypes.String, "json"),
+				"tags": tftypes.NewValue(typ.AttributeTypes["tags"], map[string]tftypes.Value{
+					"team": tftypes.NewValue(tftypes.String, "web"),
+				}),
+			})
+
+			if tt.want == "" {
+				resp := p.planResponse(typeName, tftypes.NewValue(typ, nil), config)
+				if len(resp.Diagnostics) != 1 || resp.Diagnostics[0].Severity != tfprotov6.DiagnosticSeverityError ||
+					!resp.Diagnostics[0].Attribute.Equal(tftypes.NewAttributePath().WithAttributeName("tag_format")) {
+					t.Fatalf("plan: diagnostics = %v, want an error of tag_format", resp.Diagnostics)
+				}
+				return
+			}
+			p.apply(typeName, tftypes.NewValue(typ, nil), config)
+			if description := compute.description("global/backendServices/web"); description != tt.want {
+				t.Errorf("description = %q, want %q", description, tt.want)
+			}
+		})
+	}
+}
+
+// TestLbBackendServiceTagsRegion checks that region defaults to the region of
+// the provider, and that it is kept in state once the resource is created.
+func TestLbBackendServiceTagsRegion(t *testing.T) {
+	const typeName = "st-gcp_load_balancer_backend_service_tags"
+	tests := []struct {
+		name           string
+		providerRegion string
+		region         string
+		wantRegion     string
+	}{
+		{"default to provider region", "us-east1", "", "us-east1"},
+		{"default to global", "", "", "global"},
+		{"global over provider region", "us-east1", "global", "global"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compute := newFakeComputeBackendServices(t, map[string]*googleComputeClient.BackendService{
+				"global/backendServices/web":           {Name: "web", Fingerprint: "f"},
+				"regions/us-east1/backendServices/web": {Name: "web", Fingerprint: "f"},
+			})
+			providerConfig := map[string]tftypes.Value{
+				"compute_custom_endpoint": tftypes.NewValue(tftypes.String, compute.URL+"/compute/v1/"),
+			}
+			if tt.providerRegion != "" {
+				providerConfig["region"] = tftypes.NewValue(tftypes.String, tt.providerRegion)
+				providerConfig["skip_region_validation"] = tftypes.NewValue(tftypes.Bool, true)
+			}
+			p := newTestProvider(t, providerConfig)
+			typ := p.resourceType(typeName)
+			attrs := map[string]tftypes.Value{
+				"name": tftypes.NewValue(tftypes.String, "web"),
+				"tags": tftypes.NewValue(typ.AttributeTypes["tags"], map[string]tftypes.Value{
+					"team": tftypes.NewValue(tftypes.String, "web"),
+				}),
+			}
+			if tt.region != "" {
+				attrs["region"] = tftypes.NewValue(tftypes.String, tt.region)
+			}
+			config := p.object(typ, attrs)
+
+			state, _ := p.apply(typeName, tftypes.NewValue(typ, nil), config)
+			if region := attrString(t, state, "region"); region != tt.wantRegion {
+				t.Errorf("region = %s, want %s", region, tt.wantRegion)
+			}
+			ref := stateBackendServiceRef(&lbBackendServiceTagsState{
+				Region: types.StringValue(tt.wantRegion),
+				Name:   types.StringValue("web"),
+			}, &gcpClients{project: "test-project"})
+			if id := attrString(t, state, "id"); id != ref.id() {
+				t.Errorf("id = %s, want %s", id, ref.id())
+			}
+
+			// the region is kept after the region of the provider changes.
+			providerConfig["region"] = tftypes.NewValue(tftypes.String, "europe-west1")
+			providerConfig["skip_region_validation"] = tftypes.NewValue(tftypes.Bool, true)
+			p = newTestProvider(t, providerConfig)
+			if _, requiresReplace := p.plan(typeName, state, config); len(requiresReplace) != 0 {
+				t.Errorf("changing region of the provider replaces the resource: %v", requiresReplace)
+			}
+		})
+	}
+}
+
+func TestLbBackendServiceTagsImportGlobal(t *testing.T) {
+	const typeName = "st-gcp_load_balancer_backend_service_tags"
+	compute := newFakeComputeBackendServices(t, map[string]*googleComputeClient.BackendService{
+		"global/backendServices/web": {Name: "web", Description: "team:web", Fingerprint: "f"},
+	})
+	p := newTestProvider(t, map[string]tftypes.Value{
+		"compute_custom_endpoint": tftypes.NewValue(tftypes.String, compute.URL+"/compute/v1/"),
+	})
+	state := p.importState(typeName, "projects/test-project/global/backendServices/web")
+	if region := attrString(t, state, "region"); region != "global" {
+		t.Errorf("region = %s, want global", region)
+	}
+}
+
+func TestPatchBackendServiceTagsFingerprintChanged(t *testing.T) {
+	compute := newFakeComputeBackendServices(t, map[string]*googleComputeClient.BackendService{
+		"global/backendServices/web": {Name: "web", Description: "env:dev", Fingerprint: "f"},
+	})
+	// others write the description twice between get and patch.
+	compute.concurrentWrites = []string{"note|env:dev", "note|env:dev|owner:ops"}
+	clients := newTestComputeClients(t, compute)
+	ref := backendServiceRef{project: "test-project", name: "web"}
+
+	err := patchBackendServiceTags(context.Background(), clients, ref,
+		map[string]string{"env": "prod"}, tagcodec.FormatPipeColon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if description := compute.description("global/backendServices/web"); description != "note|env:prod" {
+		t.Errorf("description = %q, want the free text of others kept", description)
+	}
+	var patches int
+	for _, path := range compute.popPaths() {
+		if path == "global/backendServices/web" {
+			patches++
+		}
+	}
+	// every attempt gets and patches the backend service.
+	if patches != 6 {
+		t.Errorf("%d requests to the backend service, want 3 attempts of get and patch", patches)
+	}
+
+	compute.concurrentWrites = make([]string, maxFingerprintRetries+1)
+	for i := range compute.concurrentWrites {
+		compute.concurrentWrites[i] = fmt.Sprintf("env:other-%d", i)
+	}
+	err = patchBackendServiceTags(context.Background(), clients, ref,
+		map[string]string{"env": "staging"}, tagcodec.FormatPipeColon)
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusPreconditionFailed {
+		t.Errorf("err = %v, want 412 once maxFingerprintRetries is reached", err)
+	}
+}
+
+func TestPatchBackendServiceOperationPolled(t *testing.T) {
+	compute := newFakeComputeBackendServices(t, map[string]*googleComputeClient.BackendService{
+		"regions/us-east1/backendServices/web": {Name: "web", Fingerprint: "f"},
+	})
+	compute.runningOperations = true
+	clients := newTestComputeClients(t, compute)
+	ref := backendServiceRef{project: "test-project", region: "us-east1", name: "web"}
+
+	if err := patchBackendService(context.Background(), clients, ref, &googleComputeClient.BackendService{
+		Description: "env:prod",
+		Fingerprint: "f",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	paths := compute.popPaths()
+	if want := "regions/us-east1/operations/operation-1"; !slices.Contains(paths, want) {
+		t.Errorf("requests to %q, want the operation polled by %s", paths, want)
+	}
+}
@@ -0,0 +1,443 @@
+package gcp
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/listvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64default"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/listplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"golang.org/x/crypto/acme"
+)
+
+var (
+	_ resource.Resource              = &publicCACertificateResource{}
+	_ resource.ResourceWithConfigure = &publicCACertificateResource{}
+)
+
+const (
+	certificateKeyTypeP256    = "P256"
+	certificateKeyTypeP384    = "P384"
+	certificateKeyTypeRSA2048 = "RSA2048"
+	certificateKeyTypeRSA4096 = "RSA4096"
+)
+
+// publicCACertificateResource Present st-gcp_public_ca_certificate resource
+type publicCACertificateResource struct {
+	client *gcpClients
+}
+
+type publicCACertificateState struct {
+	ClientConfig     *clientConfig `tfsdk:"client_config"`
+	DirectoryURL     types.String  `tfsdk:"directory_url"`
+	AccountKeyPem    types.String  `tfsdk:"account_key_pem"`
+	AccountURL       types.String  `tfsdk:"account_url"`
+	DNSNames         types.List    `tfsdk:"dns_names"`
+	KeyType          types.String  `tfsdk:"key_type"`
+	DNSProject       types.String  `tfsdk:"dns_project"`
+	ManagedZone      types.String  `tfsdk:"managed_zone"`
+	MinDaysRemaining types.Int64   `tfsdk:"min_days_remaining"`
+	CertificateURL   types.String  `tfsdk:"certificate_url"`
+	CertificatePem   types.String  `tfsdk:"certificate_pem"`
+	IssuerPem        types.String  `tfsdk:"issuer_pem"`
+	PrivateKeyPem    types.String  `tfsdk:"private_key_pem"`
+	NotAfter         types.String  `tfsdk:"not_after"`
+}
+
+// NewPublicCACertificateResource
+func NewPublicCACertificateResource() resource.Resource {
+	return &publicCACertificateResource{}
+}
+
+// Metadata
+func (r *publicCACertificateResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_public_ca_certificate"
+}
+
+// Schema
+func (r *publicCACertificateResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Issue a certificate from Google Public CA with an EAB-bound " +
+			"ACME account, DNS-01 challenges are solved by TXT records in Cloud DNS.",
+		Attributes: map[string]schema.Attribute{
+			"directory_url": &schema.StringAttribute{
+				Description: "The ACME directory URL, which must be the one the " +
+					"account is registered on. Default to the production directory " +
+					"of Google Public CA.",
+				Optional: true,
+				Computed: true,
+				Default:  stringdefault.StaticString(publicCADirectoryURLProduction),
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"account_key_pem": &schema.StringAttribute{
+				Description: "The private key of the ACME account in PEM format.",
+				Required:    true,
+				Sensitive:   true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"account_url": &schema.StringAttribute{
+				Description: "The URL of the ACME account, e.g. account_url of " +
+					"st-gcp_acme_registration.",
+				Required: true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"dns_names": &schema.ListAttribute{
+				Description: "The DNS names of the certificate, the first one is " +
+					"used as common name. Wildcard names are supported.",
+				ElementType: types.StringType,
+				Required:    true,
+				Validators: []validator.List{
+					listvalidator.SizeAtLeast(1),
+				},
+				PlanModifiers: []planmodifier.List{
+					listplanmodifier.RequiresReplace(),
+				},
+			},
+			"key_type": &schema.StringAttribute{
+				Description: "The type of the certificate private key, one of P256, " +
+					"P384, RSA2048 and RSA4096. Default to P256.",
+				Optional: true,
+				Computed: true,
+				Default:  stringdefault.StaticString(certificateKeyTypeP256),
+				Validators: []validator.String{
+					stringvalidator.OneOf(certificateKeyTypeP256, certificateKeyTypeP384,
+						certificateKeyTypeRSA2048, certificateKeyTypeRSA4096),
+				},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"dns_project": &schema.StringAttribute{
+				Description: "The project of the Cloud DNS managed zone. Default to " +
+					"the project of client_config, then the project configured in the provider.",
+				Optional: true,
+				Computed: true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"managed_zone": &schema.StringAttribute{
+				Description: "The name of the Cloud DNS managed zone to write the " +
+					"TXT records of DNS-01 challenges in.",
+				Required: true,
+			},
+			"min_days_remaining": &schema.Int64Attribute{
+				Description: "The certificate is removed from state on refresh " +
+					"once it expires within this number of days, and a new " +
+					"certificate will be issued. Default to 30.",
+				Optional: true,
+				Computed: true,
+				Default:  int64default.StaticInt64(30),
+				Validators: []validator.Int64{
+					int64validator.AtLeast(0),
+				},
+			},
+			"certificate_url": &schema.StringAttribute{
+				Description: "The URL of the certificate on the ACME server.",
+				Computed:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"certificate_pem": &schema.StringAttribute{
+				Description: "The certificate in PEM format.",
+				Computed:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"issuer_pem": &schema.StringAttribute{
+				Description: "The intermediate certificates of the issuer in PEM format.",
+				Computed:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"private_key_pem": &schema.StringAttribute{
+				Description: "The private key of the certificate in PEM format.",
+				Computed:    true,
+				Sensitive:   true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"not_after": &schema.StringAttribute{
+				Description: "The expiry time of the certificate in RFC 3339 format.",
+				Computed:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+		},
+		Blocks: map[string]schema.Block{
+			"client_config": resourceClientConfigBlock(),
+		},
+	}
+}
+
+// Configure
+func (r *publicCACertificateResource) Configure(_ context.Context,
+	req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	client, ok := req.ProviderData.(*gcpClients)
+	if !ok {
+		resp.Diagnostics.AddError("req.ProviderData not a gcpClients error", "")
+		return
+	}
+	r.client = client
+}
+
+// Create orders a certificate and solves every DNS-01 challenge of it.
+// see: https://www.rfc-editor.org/rfc/rfc8555#section-7.4
+func (r *publicCACertificateResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var plan publicCACertificateState
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		tflog.Error(ctx, "Create req.Plan.Get error")
+		return
+	}
+	clients, diags := r.client.clientsFor(ctx, plan.ClientConfig)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	var dnsNames []string
+	resp.Diagnostics.Append(plan.DNSNames.ElementsAs(ctx, &dnsNames, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if plan.DNSProject.IsUnknown() || plan.DNSProject.IsNull() {
+		plan.DNSProject = types.StringValue(clients.project)
+	}
+
+	accountKey, err := parsePrivateKey(plan.AccountKeyPem.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("account_key_pem"),
+			"Invalid ACME account key",
+			err.Error(),
+		)
+		return
+	}
+	client := clients.newAcmeClient(accountKey, plan.DirectoryURL.ValueString(), plan.AccountURL.ValueString())
+
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(dnsNames...))
+	if err != nil {
+		resp.Diagnostics.AddError("[API ERROR] Failed to create ACME order", err.Error())
+		return
+	}
+
+	solver, err := newDNS01Solver(ctx, clients, plan.DNSProject.ValueString(), plan.ManagedZone.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("[API ERROR] Failed to initialize Cloud DNS client", err.Error())
+		return
+	}
+	defer func() {
+		// ctx is cancelled on Ctrl-C or timeout, which must not leave the
+		// challenge records behind.
+		cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), dns01CleanupTimeout)
+		defer cancel()
+		if err := solver.cleanup(cleanupCtx); err != nil {
+			resp.Diagnostics.AddWarning(
+				"[API ERROR] Failed to clean up DNS-01 challenge records",
+				clients.apiErrorDetail(err),
+			)
+		}
+	}()
+	if err := r.solveAuthorizations(ctx, client, solver, order); err != nil {
+		resp.Diagnostics.AddError("[API ERROR] Failed to solve DNS-01 challenges", clients.apiErrorDetail(err))
+		return
+	}
+
+	if order, err = client.WaitOrder(ctx, order.URI); err != nil {
+		resp.Diagnostics.AddError("[API ERROR] Failed to wait for ACME order", err.Error())
+		return
+	}
+	certKey, certKeyPem, err := generateCertificateKey(plan.KeyType.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("[INTERNAL ERROR] Failed to generate certificate key", err.Error())
+		return
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: dnsNames[0]},
+		DNSNames: dnsNames,
+	}, certKey)
+	if err != nil {
+		resp.Diagnostics.AddError("[INTERNAL ERROR] Failed to create certificate request", err.Error())
+		return
+	}
+	chain, certURL, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		resp.Diagnostics.AddError("[API ERROR] Failed to finalize ACME order", err.Error())
+		return
+	}
+	leaf, err := x509.ParseCertificate(chain[0])
+	if err != nil {
+		resp.Diagnostics.AddError("[API ERROR] Failed to parse issued certificate", err.Error())
+		return
+	}
+
+	var issuerPem []byte
+	for _, der := range chain[1:] {
+		issuerPem = append(issuerPem, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	plan.CertificateURL = types.StringValue(certURL)
+	plan.CertificatePem = types.StringValue(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: chain[0]})))
+	plan.IssuerPem = types.StringValue(string(issuerPem))
+	plan.PrivateKeyPem = types.StringValue(certKeyPem)
+	plan.NotAfter = types.StringValue(leaf.NotAfter.UTC().Format(time.RFC3339))
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+// solveAuthorizations writes the TXT records of all pending authorizations at
+// once, then accepts the challenges and waits for them to be valid.
+func (r *publicCACertificateResource) solveAuthorizations(ctx context.Context,
+	client *acme.Client, solver *dns01Solver, order *acme.Order) error {
+	var challenges []*acme.Challenge
+	var authzURLs []string
+	for _, authzURL := range order.AuthzURLs {
+		authz, err := client.GetAuthorization(ctx, authzURL)
+		if err != nil {
+			return err
+		}
+		if authz.Status == acme.StatusValid {
+			continue
+		}
+		challenge, err := dns01Challenge(authz)
+		if err != nil {
+			return err
+		}
+		record, err := client.DNS01ChallengeRecord(challenge.Token)
+		if err != nil {
+			return err
+		}
+		solver.add(authz.Identifier.Value, record)
+		challenges = append(challenges, challenge)
+		authzURLs = append(authzURLs, authzURL)
+	}
+	if len(challenges) == 0 {
+		return nil
+	}
+
+	if err := solver.present(ctx); err != nil {
+		return err
+	}
+	for _, challenge := range challenges {
+		if _, err := client.Accept(ctx, challenge); err != nil {
+			return err
+		}
+	}
+	for _, authzURL := range authzURLs {
+		if _, err := client.WaitAuthorization(ctx, authzURL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Read removes the certificate from state once it expires within
+// min_days_remaining, so that a new certificate is planned to be issued.
+// Deciding the renewal on refresh keeps a saved plan consistent when it is
+// applied after the certificate enters min_days_remaining.
+func (r *publicCACertificateResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var state publicCACertificateState
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		tflog.Error(ctx, "Read req.State.Get error")
+		return
+	}
+
+	notAfter, err := time.Parse(time.RFC3339, state.NotAfter.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("[INTERNAL ERROR] Failed to parse not_after of certificate", err.Error())
+		return
+	}
+	renewAt := notAfter.AddDate(0, 0, -int(state.MinDaysRemaining.ValueInt64()))
+	if time.Now().Before(renewAt) {
+		return
+	}
+
+	resp.Diagnostics.AddWarning(
+		"Certificate will be renewed",
+		fmt.Sprintf("The certificate expires at %s, which is within %d days, "+
+			"a new certificate will be issued.", state.NotAfter.ValueString(),
+			state.MinDaysRemaining.ValueInt64()),
+	)
+	resp.State.RemoveResource(ctx)
+}
+
+// Update records the changes of attributes which only matter when a new
+// certificate is issued, e.g. managed_zone.
+func (r *publicCACertificateResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan publicCACertificateState
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if plan.DNSProject.IsUnknown() {
+		clients, diags := r.client.clientsFor(ctx, plan.ClientConfig)
+		resp.Diagnostics.Append(diags...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		plan.DNSProject = types.StringValue(clients.project)
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+// Delete removes the certificate from state, it will not be revoked.
+func (r *publicCACertificateResource) Delete(_ context.Context, _ resource.DeleteRequest, _ *resource.DeleteResponse) {
+}
+
+// generateCertificateKey generates the private key of the certificate, and
+// returns it together with its PEM encoding.
+func generateCertificateKey(keyType string) (crypto.Signer, string, error) {
+	var key crypto.Signer
+	var err error
+	switch keyType {
+	case certificateKeyTypeP384:
+		key, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case certificateKeyTypeRSA2048:
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case certificateKeyTypeRSA4096:
+		key, err = rsa.GenerateKey(rand.Reader, 4096)
+	default:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, "", err
+	}
+	return key, string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
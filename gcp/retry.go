@@ -0,0 +1,171 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+)
+
+const (
+	defaultMaxRetries     = 3
+	defaultRequestTimeout = 60 * time.Second
+)
+
+// retryPolicy bounds the retries of a request by both attempts and elapsed
+// time, whichever is reached first.
+type retryPolicy struct {
+	maxRetries int
+	timeout    time.Duration
+}
+
+// retryTransport retries requests which fail with network errors, 429 or 5xx
+// responses except 501. POST and PATCH requests are only retried on 429 and
+// connection failures, see isRetryable. Retry-After header is honoured if it
+// is present.
+type retryTransport struct {
+	base   http.RoundTripper
+	policy retryPolicy
+}
+
+// RoundTrip implements http.RoundTripper. The timeout of the policy bounds
+// every attempt, the waits in between and reading the body of the response,
+// so a hung connection can not block the request beyond it.
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := req.Context(), context.CancelFunc(func() {})
+	if t.policy.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, t.policy.timeout)
+	}
+	resp, err := t.roundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) && req.Context().Err() == nil {
+			err = fmt.Errorf("%w: request_timeout %s is exceeded", err, t.policy.timeout)
+		}
+		return nil, err
+	}
+	// the deadline is released once the body is consumed by the caller.
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func (t *retryTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	exponential := backoff.NewExponentialBackOff()
+	exponential.MaxElapsedTime = t.policy.timeout
+	exponential.Reset()
+	// stops retrying once the context is done, e.g. on interrupt or timeout.
+	b := backoff.WithContext(exponential, ctx)
+
+	for attempt := 0; ; attempt++ {
+		attemptReq := req
+		if attempt > 0 && req.Body != nil && req.Body != http.NoBody {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(ctx)
+			attemptReq.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(attemptReq)
+		if !isRetryable(req, resp, err) || attempt >= t.policy.maxRetries {
+			return resp, err
+		}
+		wait := b.NextBackOff()
+		if wait == backoff.Stop {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				if resp != nil {
+					resp.Body.Close()
+				}
+				return nil, ctxErr
+			}
+			return resp, err
+		}
+		if resp != nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				// Give up if the server asks to wait beyond the time limit.
+				if exponential.GetElapsedTime()+retryAfter > t.policy.timeout {
+					return resp, err
+				}
+				wait = retryAfter
+			}
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// cancelOnClose cancels the context of the request when the body of the
+// response is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close implements io.Closer.
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// isRetryable reports whether the request can be sent again. Requests whose
+// body can not be replayed are never retried. POST and PATCH requests, e.g.
+// creating an EAB, may have been processed when they fail with 5xx or the
+// connection breaks after they are sent, and sending them again may create
+// another EAB. So they are only retried on 429 and failures to connect, which
+// are never processed.
+func isRetryable(req *http.Request, resp *http.Response, err error) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	idempotent := req.Method != http.MethodPost && req.Method != http.MethodPatch
+	if err != nil {
+		// Errors caused by cancellation are not worth retrying.
+		if req.Context().Err() != nil {
+			return false
+		}
+		var opErr *net.OpError
+		return idempotent || (errors.As(err, &opErr) && opErr.Op == "dial")
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	// 501 means the method is not supported, e.g. listing EABs, which is
+	// never transient.
+	return idempotent && resp.StatusCode >= http.StatusInternalServerError &&
+		resp.StatusCode != http.StatusNotImplemented
+}
+
+// parseRetryAfter parses Retry-After header in either delay seconds or HTTP
+// date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
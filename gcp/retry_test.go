@@ -0,0 +1,316 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newRetryTestServer serves the statuses in order, and the last one for the
+// rest of attempts. It returns the server and the counter of attempts.
+func newRetryTestServer(t *testing.T, header http.Header, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(attempts.Add(1)) - 1
+		if i >= len(statuses) {
+			i = len(statuses) - 1
+		}
+		for name, values := range header {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(statuses[i])
+		_, _ = io.WriteString(w, http.StatusText(statuses[i]))
+	}))
+	t.Cleanup(server.Close)
+	return server, &attempts
+}
+
+func newRetryTestClient(policy retryPolicy) *http.Client {
+	return &http.Client{Transport: &retryTransport{base: http.DefaultTransport, policy: policy}}
+}
+
+func TestRetryTransportStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		statuses   []int
+		maxRetries int
+		status     int
+		attempts   int32
+	}{
+		{"ok", []int{200}, 3, 200, 1},
+		{"429 then ok", []int{429, 200}, 3, 200, 2},
+		{"5xx then ok", []int{500, 502, 503, 200}, 3, 200, 4},
+		{"max_retries", []int{503}, 2, 503, 3},
+		{"no retries", []int{503}, 0, 503, 1},
+		{"501", []int{501}, 3, 501, 1},
+		{"4xx", []int{404}, 3, 404, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, attempts := newRetryTestServer(t, nil, tt.statuses...)
+			client := newRetryTestClient(retryPolicy{maxRetries: tt.maxRetries, timeout: time.Minute})
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				t.Fatalf("read body: %v", err)
+			}
+			if resp.StatusCode != tt.status || string(body) != http.StatusText(tt.status) {
+				t.Errorf("status = %d %q, want %d", resp.StatusCode, body, tt.status)
+			}
+			if got := attempts.Load(); got != tt.attempts {
+				t.Errorf("attempts = %d, want %d", got, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestRetryTransportNonIdempotent(t *testing.T) {
+	tests := []struct {
+		method   string
+		statuses []int
+		status   int
+		attempts int32
+	}{
+		{http.MethodPost, []int{503, 200}, 503, 1},
+		{http.MethodPatch, []int{500, 200}, 500, 1},
+		{http.MethodPost, []int{429, 200}, 200, 2},
+		{http.MethodPut, []int{503, 200}, 200, 2},
+		{http.MethodDelete, []int{503, 200}, 200, 2},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %d", tt.method, tt.statuses[0]), func(t *testing.T) {
+			server, attempts := newRetryTestServer(t, nil, tt.statuses...)
+			client := newRetryTestClient(retryPolicy{maxRetries: 3, timeout: time.Minute})
+			req, err := http.NewRequest(tt.method, server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.status || attempts.Load() != tt.attempts {
+				t.Errorf("status = %d after %d attempts, want %d after %d", resp.StatusCode, attempts.Load(), tt.status, tt.attempts)
+			}
+		})
+	}
+}
+
+// TestRetryTransportNonIdempotentNetworkError checks that a POST is retried
+// when it can not connect, but not when the connection breaks after it is
+// sent, since it may have been processed.
+func TestRetryTransportNonIdempotentNetworkError(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conn.Close()
+	}))
+	t.Cleanup(server.Close)
+	client := newRetryTestClient(retryPolicy{maxRetries: 3, timeout: time.Minute})
+
+	if _, err := client.Post(server.URL, "", nil); err == nil {
+		t.Fatal("POST succeeded, want the broken connection")
+	}
+	if got := attempts.Load(); got != 1 {
+		t.Errorf("POST attempts = %d, want 1", got)
+	}
+
+	attempts.Store(0)
+	if _, err := client.Get(server.URL); err == nil {
+		t.Fatal("GET succeeded, want the broken connection")
+	}
+	if got := attempts.Load(); got < 4 {
+		t.Errorf("GET attempts = %d, want at least 4", got)
+	}
+
+	var dials atomic.Int32
+	client.Transport.(*retryTransport).base = &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dials.Add(1)
+			return nil, &net.OpError{Op: "dial", Net: network, Err: errors.New("connection refused")}
+		},
+	}
+	if _, err := client.Post(server.URL, "", nil); err == nil {
+		t.Fatal("POST succeeded, want the dial error")
+	}
+	if got := dials.Load(); got != 4 {
+		t.Errorf("POST dials = %d, want 4", got)
+	}
+}
+
+func TestRetryTransportReplaysBody(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		bodies = append(bodies, string(body))
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client := newRetryTestClient(retryPolicy{maxRetries: 3, timeout: time.Minute})
+	resp, err := client.Post(server.URL, "application/json", strings.NewReader(`{"a":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) != 2 || bodies[0] != `{"a":1}` || bodies[1] != `{"a":1}` {
+		t.Errorf("bodies = %q, want the body sent twice", bodies)
+	}
+}
+
+func TestRetryTransportRetryAfter(t *testing.T) {
+	server, attempts := newRetryTestServer(t, http.Header{"Retry-After": {"1"}}, 429, 200)
+	client := newRetryTestClient(retryPolicy{maxRetries: 3, timeout: time.Minute})
+	start := time.Now()
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || attempts.Load() != 2 {
+		t.Errorf("status = %d after %d attempts, want 200 after 2", resp.StatusCode, attempts.Load())
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, want Retry-After of 1s honoured", elapsed)
+	}
+}
+
+func TestRetryTransportRetryAfterBeyondTimeout(t *testing.T) {
+	server, attempts := newRetryTestServer(t, http.Header{"Retry-After": {"3600"}}, 429, 200)
+	client := newRetryTestClient(retryPolicy{maxRetries: 3, timeout: time.Minute})
+	start := time.Now()
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || attempts.Load() != 1 {
+		t.Errorf("status = %d after %d attempts, want 429 after 1", resp.StatusCode, attempts.Load())
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("gave up after %s, want at once", elapsed)
+	}
+}
+
+func TestRetryTransportTimeout(t *testing.T) {
+	const timeout = 300 * time.Millisecond
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{
+			// the connection hangs before the response.
+			name: "hung response",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+			},
+		},
+		{
+			// every attempt fails, so the waits between them reach the timeout.
+			name: "retries",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			t.Cleanup(server.Close)
+			client := newRetryTestClient(retryPolicy{maxRetries: 100, timeout: timeout})
+
+			start := time.Now()
+			resp, err := client.Get(server.URL)
+			elapsed := time.Since(start)
+			if err == nil {
+				resp.Body.Close()
+				if resp.StatusCode == http.StatusOK {
+					t.Fatal("request succeeded")
+				}
+			} else if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("err = %v, want context.DeadlineExceeded", err)
+			}
+			if elapsed > timeout+2*time.Second {
+				t.Errorf("request returned after %s, want bounded by %s", elapsed, timeout)
+			}
+		})
+	}
+}
+
+func TestRetryTransportTimeoutReadingBody(t *testing.T) {
+	const timeout = 300 * time.Millisecond
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the body hangs after the headers are sent.
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+	client := newRetryTestClient(retryPolicy{maxRetries: 3, timeout: timeout})
+
+	start := time.Now()
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if _, err := io.ReadAll(resp.Body); err == nil {
+		t.Error("reading the hung body succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > timeout+2*time.Second {
+		t.Errorf("reading body returned after %s, want bounded by %s", elapsed, timeout)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		wait  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"0", 0, true},
+		{"120", 120 * time.Second, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, true},
+	}
+	for _, tt := range tests {
+		wait, ok := parseRetryAfter(tt.value)
+		if wait != tt.wait || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %s, %v, want %s, %v", tt.value, wait, ok, tt.wait, tt.ok)
+		}
+	}
+
+	// an HTTP date in the future is the time to wait until.
+	wait, ok := parseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	if !ok || wait <= 0 || wait > time.Minute {
+		t.Errorf("parseRetryAfter(date in a minute) = %s, %v", wait, ok)
+	}
+}
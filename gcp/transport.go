@@ -0,0 +1,177 @@
+package gcp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"golang.org/x/oauth2"
+)
+
+const redacted = "REDACTED"
+
+// redactedHeaders are headers carrying credentials which are never logged.
+var redactedHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Goog-Api-Key",
+}
+
+// redactedBodyRegexp matches JSON fields carrying secrets, e.g. the HMAC of
+// EAB and tokens of IAM Credentials API.
+var redactedBodyRegexp = regexp.MustCompile(
+	`"(b64MacKey|accessToken|access_token|refresh_token|id_token|private_key|client_secret)"\s*:\s*"[^"]*"`)
+
+// newHTTPClient returns an HTTP client which authenticates requests with the
+// token source, and sets the user agent, retries by the policy and logs every
+// attempt. Service clients and raw REST calls of Google Cloud APIs should all
+// be built from it, or use httpClient of gcpClients directly.
+func newHTTPClient(tokenSource oauth2.TokenSource, userAgent string, policy retryPolicy) *http.Client {
+	return &http.Client{
+		Transport: &authTransport{
+			source: tokenSource,
+			base: &userAgentTransport{
+				userAgent: userAgent,
+				base: &retryTransport{
+					base:   &loggingTransport{base: http.DefaultTransport},
+					policy: policy,
+				},
+			},
+		},
+	}
+}
+
+// authTransport sets the access token of the token source on requests. Unlike
+// oauth2.Transport, it stops waiting for the token once the context of the
+// request is done, since token sources such as impersonation make requests
+// of their own which know nothing about the context.
+type authTransport struct {
+	source oauth2.TokenSource
+	base   http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := tokenWithContext(req.Context(), t.source)
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	authReq := req.Clone(req.Context())
+	token.SetAuthHeader(authReq)
+	return t.base.RoundTrip(authReq)
+}
+
+// tokenWithContext returns the token of the token source, or the error of the
+// context if it is done first.
+func tokenWithContext(ctx context.Context, source oauth2.TokenSource) (*oauth2.Token, error) {
+	type result struct {
+		token *oauth2.Token
+		err   error
+	}
+	// buffered so that the goroutine can finish after the context is done.
+	ch := make(chan result, 1)
+	go func() {
+		token, err := source.Token()
+		ch <- result{token, err}
+	}()
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-ch:
+		return r.token, r.err
+	}
+}
+
+// userAgentTransport sets the user agent of the provider on requests.
+type userAgentTransport struct {
+	userAgent string
+	base      http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	uaReq := req.Clone(req.Context())
+	uaReq.Header.Set("User-Agent", t.userAgent)
+	return t.base.RoundTrip(uaReq)
+}
+
+// loggingTransport logs every attempt of requests and their responses through
+// tflog, with credentials and secrets redacted. Bodies are only logged at
+// trace level.
+type loggingTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	fields := map[string]interface{}{
+		"method":  req.Method,
+		"url":     req.URL.Redacted(),
+		"headers": redactHeaders(req.Header),
+	}
+	tflog.Debug(ctx, "Sending HTTP request", fields)
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			if data, err := io.ReadAll(body); err == nil {
+				tflog.Trace(ctx, "HTTP request body", map[string]interface{}{
+					"body": redactBody(data),
+				})
+			}
+			body.Close()
+		}
+	}
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	fields["duration"] = time.Since(start).String()
+	if err != nil {
+		fields["error"] = err.Error()
+		tflog.Debug(ctx, "HTTP request failed", fields)
+		return resp, err
+	}
+	fields["status"] = resp.StatusCode
+	fields["headers"] = redactHeaders(resp.Header)
+	tflog.Debug(ctx, "Received HTTP response", fields)
+
+	// only JSON responses are small enough to be buffered for logging.
+	if strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(data))
+		tflog.Trace(ctx, "HTTP response body", map[string]interface{}{
+			"body": redactBody(data),
+		})
+	}
+	return resp, nil
+}
+
+func redactHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+	for name, values := range header {
+		headers[name] = strings.Join(values, ", ")
+	}
+	for _, name := range redactedHeaders {
+		if _, ok := headers[name]; ok {
+			headers[name] = redacted
+		}
+	}
+	return headers
+}
+
+func redactBody(body []byte) string {
+	return redactedBodyRegexp.ReplaceAllString(string(body), `"$1":"`+redacted+`"`)
+}
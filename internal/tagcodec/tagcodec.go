@@ -0,0 +1,467 @@
+// Package tagcodec encodes and decodes the tags kept in the description of
+// Google Cloud resources, which have no labels. Descriptions are written by
+// different tools, so several formats are supported:
+//
+//	pipe_colon:   TagKey:TagValue|TagKey:TagValue
+//	kv_semicolon: TagKey=TagValue;TagKey=TagValue
+//	json:         {"TagKey":"TagValue","TagKey":"TagValue"}
+//
+// In pipe_colon and kv_semicolon, separators and escapes in keys and values
+// are escaped with a backslash, e.g. "url:http\://example.com\:8080". The
+// first unescaped key-value separator of an item separates the key and the
+// value, so values written by hand with separators, e.g. URLs and ports, are
+// decoded as is.
+package tagcodec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Format is the format of tags in descriptions.
+type Format string
+
+const (
+	// FormatPipeColon is TagKey:TagValue|TagKey:TagValue.
+	FormatPipeColon Format = "pipe_colon"
+	// FormatKVSemicolon is TagKey=TagValue;TagKey=TagValue.
+	FormatKVSemicolon Format = "kv_semicolon"
+	// FormatJSON is a JSON object of string values.
+	FormatJSON Format = "json"
+	// FormatAuto detects the format of every description when decoding, and
+	// encodes in FormatPipeColon.
+	FormatAuto Format = "auto"
+)
+
+// Formats returns the names of all formats.
+func Formats() []string {
+	return []string{
+		string(FormatPipeColon),
+		string(FormatKVSemicolon),
+		string(FormatJSON),
+		string(FormatAuto),
+	}
+}
+
+// EscapeChar escapes separators and itself in keys and values of pipe_colon
+// and kv_semicolon.
+const EscapeChar = '\\'
+
+// dialect is the separators of a delimited format.
+type dialect struct {
+	itemSeparator     byte
+	keyValueSeparator byte
+}
+
+var (
+	pipeColon   = dialect{itemSeparator: '|', keyValueSeparator: ':'}
+	kvSemicolon = dialect{itemSeparator: ';', keyValueSeparator: '='}
+)
+
+// Mode decides how malformed items are handled by Decode.
+type Mode int
+
+const (
+	// Lenient skips malformed items and reports them as warnings. Invalid
+	// escapes are kept literally and reported, since free text written by
+	// hand, e.g. Windows paths, may contain backslashes.
+	Lenient Mode = iota
+	// Strict fails on the first malformed item or invalid escape.
+	Strict
+)
+
+// ErrMalformed is wrapped by the errors of malformed items.
+var ErrMalformed = errors.New("malformed tag")
+
+// ErrFreeTextNotJSON is wrapped by the error of Join when free text can not be
+// kept in json, e.g. the description is not a JSON object.
+var ErrFreeTextNotJSON = errors.New("free text can not be kept in a JSON object")
+
+// ItemError is a malformed item of the description.
+type ItemError struct {
+	// Index of the item in the description, starting from 0. It is -1 if the
+	// whole description is malformed, e.g. invalid JSON.
+	Index int
+	// Item is the raw item, which is still escaped.
+	Item string
+	Err  error
+}
+
+func (e *ItemError) Error() string {
+	if e.Index < 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("tag %d %q: %v", e.Index, e.Item, e.Err)
+}
+
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
+
+// Warning is a problem of an item found in Lenient mode.
+type Warning struct {
+	// Index of the item in the description, starting from 0. It is -1 if the
+	// whole description is malformed, e.g. invalid JSON.
+	Index int
+	// Item is the raw item, which is still escaped.
+	Item string
+	// Skipped reports whether the item is left out of the decoded tags.
+	Skipped bool
+	Message string
+}
+
+func (w Warning) String() string {
+	if w.Index < 0 {
+		return w.Message
+	}
+	if w.Skipped {
+		return fmt.Sprintf("tag %d %q is skipped: %s", w.Index, w.Item, w.Message)
+	}
+	return fmt.Sprintf("tag %d %q: %s", w.Index, w.Item, w.Message)
+}
+
+// Decode decodes the tags of the description in the format. An empty
+// description has no tags. In Strict mode an *ItemError is returned for the
+// first malformed item, while in Lenient mode malformed items are reported as
+// warnings. A key repeated in Lenient mode takes the last value.
+func Decode(description string, format Format, mode Mode) (map[string]string, []Warning, error) {
+	if description == "" {
+		return map[string]string{}, nil, nil
+	}
+	if format == FormatAuto {
+		format = Detect(description)
+	}
+	switch format {
+	case FormatPipeColon:
+		return decodeDelimited(description, pipeColon, mode)
+	case FormatKVSemicolon:
+		return decodeDelimited(description, kvSemicolon, mode)
+	case FormatJSON:
+		return decodeJSON(description, mode)
+	}
+	return nil, nil, fmt.Errorf("unknown tag format %q", format)
+}
+
+// Detect returns the format of the description: json if it is a JSON object,
+// otherwise the delimited format whose key-value separator comes first, and
+// pipe_colon if there is neither, e.g. free text.
+func Detect(description string) Format {
+	trimmed := strings.TrimSpace(description)
+	if strings.HasPrefix(trimmed, "{") && json.Valid([]byte(trimmed)) {
+		return FormatJSON
+	}
+	colon := indexUnescaped(description, pipeColon.keyValueSeparator)
+	equal := indexUnescaped(description, kvSemicolon.keyValueSeparator)
+	if equal >= 0 && (colon < 0 || equal < colon) {
+		return FormatKVSemicolon
+	}
+	return FormatPipeColon
+}
+
+// Encode encodes the tags into a description in the format, sorted by key so
+// that the result is stable. No tags are encoded into an empty description.
+func Encode(tags map[string]string, format Format) (string, error) {
+	if len(tags) == 0 {
+		return "", nil
+	}
+	switch format {
+	case FormatPipeColon, FormatAuto:
+		return encodeDelimited(tags, pipeColon), nil
+	case FormatKVSemicolon:
+		return encodeDelimited(tags, kvSemicolon), nil
+	case FormatJSON:
+		// keys of maps are sorted by encoding/json. HTML characters are kept
+		// as is, since descriptions are never rendered as HTML.
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(tags); err != nil {
+			return "", fmt.Errorf("failed to marshal tags: %v", err)
+		}
+		return strings.TrimSuffix(buf.String(), "\n"), nil
+	}
+	return "", fmt.Errorf("unknown tag format %q", format)
+}
+
+// Split decodes the tags of the description in Lenient mode, and returns the
+// raw items which are not tags, e.g. free text, together with the format the
+// description is decoded in. An empty description is in FormatPipeColon if
+// the format is FormatAuto.
+func Split(description string, format Format) (map[string]string, []string, Format, error) {
+	if format == FormatAuto {
+		format = FormatPipeColon
+		if description != "" {
+			format = Detect(description)
+		}
+	}
+	tags, warnings, err := Decode(description, format, Lenient)
+	if err != nil {
+		return nil, nil, format, err
+	}
+	var freeText []string
+	for _, warning := range warnings {
+		if warning.Skipped && warning.Item != "" {
+			freeText = append(freeText, warning.Item)
+		}
+	}
+	return tags, freeText, format, nil
+}
+
+// Join encodes the tags into a description after the free text returned by
+// Split, so that the free text is kept as is. Free text can only be kept in
+// json if it is members of the JSON object whose values are not tags, e.g.
+// null, and members with the same keys as tags are replaced.
+func Join(freeText []string, tags map[string]string, format Format) (string, error) {
+	if len(freeText) == 0 {
+		return Encode(tags, format)
+	}
+	switch format {
+	case FormatPipeColon, FormatAuto:
+		return joinDelimited(freeText, tags, pipeColon), nil
+	case FormatKVSemicolon:
+		return joinDelimited(freeText, tags, kvSemicolon), nil
+	case FormatJSON:
+		return joinJSON(freeText, tags)
+	}
+	return "", fmt.Errorf("unknown tag format %q", format)
+}
+
+func joinDelimited(freeText []string, tags map[string]string, d dialect) string {
+	items := append([]string{}, freeText...)
+	if len(tags) > 0 {
+		items = append(items, encodeDelimited(tags, d))
+	}
+	return strings.Join(items, string(d.itemSeparator))
+}
+
+func joinJSON(freeText []string, tags map[string]string) (string, error) {
+	var members []string
+	for _, item := range freeText {
+		var member map[string]json.RawMessage
+		if err := json.Unmarshal([]byte("{"+item+"}"), &member); err != nil || len(member) != 1 {
+			return "", fmt.Errorf("%w: %q", ErrFreeTextNotJSON, item)
+		}
+		for key := range member {
+			if _, ok := tags[key]; !ok {
+				members = append(members, item)
+			}
+		}
+	}
+	if len(tags) > 0 {
+		encoded, err := Encode(tags, FormatJSON)
+		if err != nil {
+			return "", err
+		}
+		members = append(members, strings.TrimSuffix(strings.TrimPrefix(encoded, "{"), "}"))
+	}
+	return "{" + strings.Join(members, ",") + "}", nil
+}
+
+func decodeDelimited(description string, d dialect, mode Mode) (map[string]string, []Warning, error) {
+	tags := map[string]string{}
+	var warnings []Warning
+	for i, item := range splitUnescaped(description, d.itemSeparator) {
+		key, value, problems, err := decodeItem(item, d)
+		if err == nil {
+			if _, ok := tags[key]; ok {
+				problems = append(problems, fmt.Sprintf("duplicate key %q", key))
+			}
+		}
+		if mode == Strict {
+			if err == nil && len(problems) > 0 {
+				err = fmt.Errorf("%w: %s", ErrMalformed, strings.Join(problems, ", "))
+			}
+			if err != nil {
+				return nil, nil, &ItemError{Index: i, Item: item, Err: err}
+			}
+		}
+		if err != nil {
+			warnings = append(warnings, Warning{
+				Index:   i,
+				Item:    item,
+				Skipped: true,
+				Message: err.Error(),
+			})
+			continue
+		}
+		for _, problem := range problems {
+			warnings = append(warnings, Warning{
+				Index:   i,
+				Item:    item,
+				Message: problem,
+			})
+		}
+		tags[key] = value
+	}
+	return tags, warnings, nil
+}
+
+// decodeItem decodes an item into its key and value. Invalid escapes are
+// kept literally and reported as problems, while an item without key-value
+// separator or key is an error.
+func decodeItem(item string, d dialect) (string, string, []string, error) {
+	if item == "" {
+		return "", "", nil, fmt.Errorf("%w: empty item", ErrMalformed)
+	}
+	i := indexUnescaped(item, d.keyValueSeparator)
+	if i < 0 {
+		return "", "", nil, fmt.Errorf("%w: missing %q between key and value", ErrMalformed, d.keyValueSeparator)
+	}
+	key, keyProblems := unescape(item[:i], d)
+	value, valueProblems := unescape(item[i+1:], d)
+	if key == "" {
+		return "", "", nil, fmt.Errorf("%w: empty key", ErrMalformed)
+	}
+	return key, value, append(keyProblems, valueProblems...), nil
+}
+
+// decodeJSON decodes a JSON object. Numbers and booleans are kept as their
+// JSON text, while null, objects and arrays are malformed items.
+func decodeJSON(description string, mode Mode) (map[string]string, []Warning, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(description), &object); err != nil {
+		err = fmt.Errorf("%w: description is not a JSON object: %v", ErrMalformed, err)
+		if mode == Strict {
+			return nil, nil, &ItemError{Index: -1, Item: description, Err: err}
+		}
+		return map[string]string{}, []Warning{{
+			Index:   -1,
+			Item:    description,
+			Skipped: true,
+			Message: err.Error(),
+		}}, nil
+	}
+
+	// keys are sorted so that indexes of items are stable.
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	tags := map[string]string{}
+	var warnings []Warning
+	for i, key := range keys {
+		raw := object[key]
+		// items are raw JSON members, so that they can be joined back.
+		keyJSON, _ := json.Marshal(key)
+		item := string(keyJSON) + ":" + string(raw)
+		var err error
+		var value string
+		switch {
+		case key == "":
+			err = fmt.Errorf("%w: empty key", ErrMalformed)
+		case len(raw) > 0 && raw[0] == '"':
+			err = json.Unmarshal(raw, &value)
+		case len(raw) > 0 && (raw[0] == '{' || raw[0] == '[' || raw[0] == 'n'):
+			err = fmt.Errorf("%w: value must be a string, number or boolean", ErrMalformed)
+		default:
+			value = string(raw)
+		}
+		if err != nil {
+			if mode == Strict {
+				return nil, nil, &ItemError{Index: i, Item: item, Err: err}
+			}
+			warnings = append(warnings, Warning{
+				Index:   i,
+				Item:    item,
+				Skipped: true,
+				Message: err.Error(),
+			})
+			continue
+		}
+		tags[key] = value
+	}
+	return tags, warnings, nil
+}
+
+func encodeDelimited(tags map[string]string, d dialect) string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	items := make([]string, 0, len(keys))
+	for _, key := range keys {
+		items = append(items, escape(key, d)+string(d.keyValueSeparator)+escape(tags[key], d))
+	}
+	return strings.Join(items, string(d.itemSeparator))
+}
+
+// escape escapes separators and escapes in s, so that it can be used as a key
+// or value.
+func escape(s string, d dialect) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case d.itemSeparator, d.keyValueSeparator, EscapeChar:
+			b.WriteByte(EscapeChar)
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// unescape resolves the escapes of s. Escapes of characters other than
+// separators and itself, and a dangling escape at the end, are kept literally
+// and reported.
+func unescape(s string, d dialect) (string, []string) {
+	var b strings.Builder
+	var problems []string
+	for i := 0; i < len(s); i++ {
+		if s[i] != EscapeChar {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+1 == len(s) {
+			problems = append(problems, "dangling escape at the end")
+			b.WriteByte(s[i])
+			continue
+		}
+		switch s[i+1] {
+		case d.itemSeparator, d.keyValueSeparator, EscapeChar:
+			b.WriteByte(s[i+1])
+		default:
+			problems = append(problems, fmt.Sprintf("invalid escape %q", s[i:i+2]))
+			b.WriteString(s[i : i+2])
+		}
+		i++
+	}
+	return b.String(), problems
+}
+
+// splitUnescaped splits s at every sep which is not escaped, keeping escapes
+// in the parts.
+func splitUnescaped(s string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case EscapeChar:
+			i++
+		case sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// indexUnescaped returns the index of the first c in s which is not escaped,
+// or -1 if there is none.
+func indexUnescaped(s string, c byte) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case EscapeChar:
+			i++
+		case c:
+			return i
+		}
+	}
+	return -1
+}
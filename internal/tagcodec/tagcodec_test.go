@@ -0,0 +1,306 @@
+package tagcodec
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name        string
+		description string
+		format      Format
+		tags        map[string]string
+		// warnings are the indexes of items with warnings in Lenient mode,
+		// and skipped are the ones left out of tags.
+		warnings []int
+		skipped  []int
+		// strictIndex is the index of the item failing Strict mode, or nil
+		// if it succeeds with the same tags.
+		strictIndex *int
+	}{
+		{
+			name:        "empty",
+			description: "",
+			format:      FormatPipeColon,
+			tags:        map[string]string{},
+		},
+		{
+			name:        "tags",
+			description: "env:prod|team:web",
+			format:      FormatPipeColon,
+			tags:        map[string]string{"env": "prod", "team": "web"},
+		},
+		{
+			name:        "unescaped url",
+			description: "url:https://example.com:8443/path|env:prod",
+			format:      FormatPipeColon,
+			tags:        map[string]string{"url": "https://example.com:8443/path", "env": "prod"},
+		},
+		{
+			name:        "unescaped port",
+			description: "host:db.internal:5432",
+			format:      FormatPipeColon,
+			tags:        map[string]string{"host": "db.internal:5432"},
+		},
+		{
+			name:        "escaped separators",
+			description: `url:http\://example.com\:8080|cmd:a\|b\\c`,
+			format:      FormatPipeColon,
+			tags:        map[string]string{"url": "http://example.com:8080", "cmd": `a|b\c`},
+		},
+		{
+			name:        "unescaped url in kv_semicolon",
+			description: "url=https://example.com/?a=b;env=prod",
+			format:      FormatKVSemicolon,
+			tags:        map[string]string{"url": "https://example.com/?a=b", "env": "prod"},
+		},
+		{
+			name:        "free text",
+			description: "managed by hand",
+			format:      FormatPipeColon,
+			tags:        map[string]string{},
+			warnings:    []int{0},
+			skipped:     []int{0},
+			strictIndex: ptr(0),
+		},
+		{
+			name:        "free text before tags",
+			description: "created by bob|env:prod",
+			format:      FormatPipeColon,
+			tags:        map[string]string{"env": "prod"},
+			warnings:    []int{0},
+			skipped:     []int{0},
+			strictIndex: ptr(0),
+		},
+		{
+			name:        "empty item",
+			description: "env:prod||team:web",
+			format:      FormatPipeColon,
+			tags:        map[string]string{"env": "prod", "team": "web"},
+			warnings:    []int{1},
+			skipped:     []int{1},
+			strictIndex: ptr(1),
+		},
+		{
+			name:        "empty key",
+			description: ":prod",
+			format:      FormatPipeColon,
+			tags:        map[string]string{},
+			warnings:    []int{0},
+			skipped:     []int{0},
+			strictIndex: ptr(0),
+		},
+		{
+			name:        "trailing backslash",
+			description: `env:prod|path:C\`,
+			format:      FormatPipeColon,
+			tags:        map[string]string{"env": "prod", "path": `C\`},
+			warnings:    []int{1},
+			strictIndex: ptr(1),
+		},
+		{
+			name:        "invalid escape",
+			description: `path:C:\dir`,
+			format:      FormatPipeColon,
+			tags:        map[string]string{"path": `C:\dir`},
+			warnings:    []int{0},
+			strictIndex: ptr(0),
+		},
+		{
+			name:        "duplicate keys",
+			description: "env:dev|team:web|env:prod",
+			format:      FormatPipeColon,
+			tags:        map[string]string{"env": "prod", "team": "web"},
+			warnings:    []int{2},
+			strictIndex: ptr(2),
+		},
+		{
+			name:        "json",
+			description: `{"env":"prod","port":8080,"public":true}`,
+			format:      FormatJSON,
+			tags:        map[string]string{"env": "prod", "port": "8080", "public": "true"},
+		},
+		{
+			name:        "json with null",
+			description: `{"env":"prod","note":null}`,
+			format:      FormatJSON,
+			tags:        map[string]string{"env": "prod"},
+			warnings:    []int{1},
+			skipped:     []int{1},
+			strictIndex: ptr(1),
+		},
+		{
+			name:        "invalid json",
+			description: `{"env":`,
+			format:      FormatJSON,
+			tags:        map[string]string{},
+			warnings:    []int{-1},
+			skipped:     []int{-1},
+			strictIndex: ptr(-1),
+		},
+		{
+			name:        "auto detects kv_semicolon",
+			description: "env=prod;url=http://example.com",
+			format:      FormatAuto,
+			tags:        map[string]string{"env": "prod", "url": "http://example.com"},
+		},
+		{
+			name:        "auto detects json",
+			description: ` {"env":"prod"} `,
+			format:      FormatAuto,
+			tags:        map[string]string{"env": "prod"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tags, warnings, err := Decode(tt.description, tt.format, Lenient)
+			if err != nil {
+				t.Fatalf("Lenient: %v", err)
+			}
+			if !maps.Equal(tags, tt.tags) {
+				t.Errorf("Lenient: tags = %q, want %q", tags, tt.tags)
+			}
+			var warned, skipped []int
+			for _, warning := range warnings {
+				warned = append(warned, warning.Index)
+				if warning.Skipped {
+					skipped = append(skipped, warning.Index)
+				}
+			}
+			if !slices.Equal(warned, tt.warnings) || !slices.Equal(skipped, tt.skipped) {
+				t.Errorf("Lenient: warnings = %v, want items %v with %v skipped", warnings, tt.warnings, tt.skipped)
+			}
+
+			tags, _, err = Decode(tt.description, tt.format, Strict)
+			if tt.strictIndex == nil {
+				if err != nil {
+					t.Fatalf("Strict: %v", err)
+				}
+				if !maps.Equal(tags, tt.tags) {
+					t.Errorf("Strict: tags = %q, want %q", tags, tt.tags)
+				}
+				return
+			}
+			var itemErr *ItemError
+			if !errors.As(err, &itemErr) || !errors.Is(err, ErrMalformed) {
+				t.Fatalf("Strict: err = %v, want *ItemError of ErrMalformed", err)
+			}
+			if itemErr.Index != *tt.strictIndex {
+				t.Errorf("Strict: error of item %d, want %d", itemErr.Index, *tt.strictIndex)
+			}
+		})
+	}
+}
+
+func TestSplitJoinKeepsFreeText(t *testing.T) {
+	tests := []struct {
+		description string
+		format      Format
+		tags        map[string]string
+		want        string
+	}{
+		{"managed by hand|env:dev", FormatAuto, map[string]string{"env": "prod"}, "managed by hand|env:prod"},
+		{"managed by hand|env:dev", FormatPipeColon, nil, "managed by hand"},
+		{"note;env=dev", FormatKVSemicolon, map[string]string{"env": "prod", "team": "web"}, "note;env=prod;team=web"},
+		{`{"env":"dev","note":null}`, FormatJSON, map[string]string{"env": "prod"}, `{"note":null,"env":"prod"}`},
+		{"", FormatAuto, map[string]string{"url": "http://example.com"}, `url:http\://example.com`},
+	}
+	for _, tt := range tests {
+		_, freeText, format, err := Split(tt.description, tt.format)
+		if err != nil {
+			t.Fatalf("Split(%q): %v", tt.description, err)
+		}
+		got, err := Join(freeText, tt.tags, format)
+		if err != nil {
+			t.Fatalf("Join(%q): %v", freeText, err)
+		}
+		if got != tt.want {
+			t.Errorf("Join(Split(%q)) = %q, want %q", tt.description, got, tt.want)
+		}
+	}
+}
+
+func TestJoinFreeTextNotJSON(t *testing.T) {
+	_, freeText, format, err := Split("managed by hand", FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Join(freeText, map[string]string{"env": "prod"}, format); !errors.Is(err, ErrFreeTextNotJSON) {
+		t.Errorf("Join = %v, want ErrFreeTextNotJSON", err)
+	}
+}
+
+// FuzzDecode checks that no description panics in any format or mode, that
+// Lenient mode never fails, and that a description decoded in Strict mode is
+// decoded into the same tags without warnings in Lenient mode.
+func FuzzDecode(f *testing.F) {
+	for _, seed := range []string{
+		"",
+		"env:prod|team:web",
+		"url:https://example.com:8443/path",
+		`url:http\://example.com\:8080|cmd:a\|b\\c`,
+		"managed by hand",
+		`path:C\`,
+		`\`,
+		"|:|",
+		"env=prod;url=http://example.com",
+		`{"env":"prod","port":8080,"note":null}`,
+		`{"env":`,
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, description string) {
+		for _, format := range Formats() {
+			strictTags, _, strictErr := Decode(description, Format(format), Strict)
+			tags, warnings, err := Decode(description, Format(format), Lenient)
+			if err != nil {
+				t.Fatalf("%s: Lenient: %v", format, err)
+			}
+			if strictErr == nil && (len(warnings) > 0 || !maps.Equal(tags, strictTags)) {
+				t.Errorf("%s: Lenient = %q with warnings %v, want %q of Strict", format, tags, warnings, strictTags)
+			}
+			if _, _, _, err := Split(description, Format(format)); err != nil {
+				t.Errorf("%s: Split: %v", format, err)
+			}
+		}
+	})
+}
+
+// FuzzEncodeDecode checks that encoded tags are decoded into the same tags in
+// Strict mode in every format.
+func FuzzEncodeDecode(f *testing.F) {
+	f.Add("env", "prod", "url", "https://example.com:8443/a?b=c;d|e")
+	f.Add(`path`, `C:\dir\`, "", "")
+	f.Add("k:e|y", `v\a:l=u;e`, `{"json"}`, `"quoted"`)
+	f.Fuzz(func(t *testing.T, key1, value1, key2, value2 string) {
+		tags := map[string]string{}
+		for _, tag := range [][2]string{{key1, value1}, {key2, value2}} {
+			// empty keys are malformed, and JSON can only hold UTF-8.
+			if tag[0] == "" || !utf8.ValidString(tag[0]) || !utf8.ValidString(tag[1]) {
+				continue
+			}
+			tags[tag[0]] = tag[1]
+		}
+		for _, format := range []Format{FormatPipeColon, FormatKVSemicolon, FormatJSON} {
+			description, err := Encode(tags, format)
+			if err != nil {
+				t.Fatalf("%s: Encode(%q): %v", format, tags, err)
+			}
+			decoded, _, err := Decode(description, format, Strict)
+			if err != nil {
+				t.Fatalf("%s: Decode(%q): %v", format, description, err)
+			}
+			if !maps.Equal(decoded, tags) {
+				t.Errorf("%s: Decode(Encode(%q)) = %q via %q", format, tags, decoded, description)
+			}
+		}
+	})
+}
+
+func ptr(i int) *int {
+	return &i
+}
@@ -9,6 +9,9 @@ import (
 	"github.com/myklst/terraform-provider-st-gcp/gcp"
 )
 
+// version is set by the release build through ldflags.
+var version = "dev"
+
 // Provider documentation generation.
 //go:generate go run github.com/hashicorp/terraform-plugin-docs/cmd/tfplugindocs generate --provider-name st-gcp
 
@@ -17,7 +20,7 @@ func main() {
 	if providerAddress == "" {
 		providerAddress = "registry.terraform.io/myklst/st-gcp"
 	}
-	_ = providerserver.Serve(context.Background(), gcp.New, providerserver.ServeOpts{
+	_ = providerserver.Serve(context.Background(), gcp.New(version), providerserver.ServeOpts{
 		Address: providerAddress,
 	})
 }